@@ -0,0 +1,134 @@
+// Pine's A/B testing support
+// New builds a handler that splits traffic between variants by weight, so
+// experiments run inside the app without an external proxy. Assignment is
+// sticky through a cookie, a client keeps seeing the variant it was first
+// given for the lifetime of the experiment
+//
+//	app.Get("/checkout", ab.New("checkout-v2", []ab.Variant{
+//		{Name: "control", Weight: 80, Handler: oldCheckout},
+//		{Name: "variant", Weight: 20, Handler: newCheckout},
+//	}))
+//
+// The chosen variant name is stored in the locals under LocalsKey and
+// echoed in the X-AB-Variant header for log correlation
+
+package ab
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Variant is one arm of an experiment
+type Variant struct {
+	// Name identifies the variant in cookies, locals and logs
+	Name string
+
+	// Weight is the variant's relative share of unassigned traffic
+	Weight int
+
+	// Handler serves the variant
+	Handler pine.Handler
+}
+
+type Config struct {
+	// CookiePrefix is prepended to the experiment name to form the sticky
+	// cookie's name
+	//
+	// Default: "pine_ab_"
+	CookiePrefix string
+
+	// CookieTTL is how long an assignment sticks
+	//
+	// Default: 30 days
+	CookieTTL time.Duration
+
+	// DisableSticky turns off the assignment cookie, every request is
+	// then re-rolled which suits weight based canary style splits
+	//
+	// Default: false
+	DisableSticky bool
+}
+
+// LocalsKey is the locals key the chosen variant name is stored under
+const LocalsKey = "ab_variant"
+
+// New builds the splitting handler for one experiment
+func New(experiment string, variants []Variant, config ...Config) pine.Handler {
+	cfg := Config{
+		CookiePrefix: "pine_ab_",
+		CookieTTL:    30 * 24 * time.Hour,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.CookiePrefix != "" {
+			cfg.CookiePrefix = userConfig.CookiePrefix
+		}
+		if userConfig.CookieTTL > 0 {
+			cfg.CookieTTL = userConfig.CookieTTL
+		}
+		cfg.DisableSticky = userConfig.DisableSticky
+	}
+
+	cookieName := cfg.CookiePrefix + experiment
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+
+	return func(c *pine.Ctx) error {
+		variant := assigned(c, cookieName, variants)
+		if variant == nil {
+			variant = pick(variants, total)
+			if !cfg.DisableSticky {
+				http.SetCookie(c.Response, &http.Cookie{
+					Name:     cookieName,
+					Value:    variant.Name,
+					Path:     "/",
+					HttpOnly: true,
+					MaxAge:   int(cfg.CookieTTL.Seconds()),
+				})
+			}
+		}
+
+		c.Locals(LocalsKey, variant.Name)
+		c.Set("X-AB-Variant", variant.Name)
+		return variant.Handler(c)
+	}
+}
+
+// assigned returns the variant a previous visit stuck the client to, nil
+// when there is none or the variant no longer exists
+func assigned(c *pine.Ctx, cookieName string, variants []Variant) *Variant {
+	cookie, err := c.Request.Cookie(cookieName)
+	if err != nil {
+		return nil
+	}
+	for i := range variants {
+		if variants[i].Name == cookie.Value {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// pick rolls a weighted choice across the variants
+func pick(variants []Variant, total int) *Variant {
+	if total <= 0 {
+		return &variants[0]
+	}
+	roll := rand.Intn(total)
+	for i := range variants {
+		roll -= variants[i].Weight
+		if roll < 0 {
+			return &variants[i]
+		}
+	}
+	return &variants[len(variants)-1]
+}
@@ -0,0 +1,142 @@
+// Accept header negotiation helpers
+// Handlers that can answer in more than one representation ask these
+// helpers which one the client prefers. The Accept style headers are
+// parsed with their q values so "application/json;q=0.9, text/html"
+// really does prefer HTML, wildcards like */* and text/* are honoured
+
+package pine
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptSpec is one parsed entry of an Accept style header
+type acceptSpec struct {
+	value string
+	q     float64
+}
+
+// parseAccept splits an Accept style header into its entries sorted by
+// quality, highest first. Entries with q=0 are excluded, the client has
+// explicitly refused them
+func parseAccept(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		value := part
+		q := 1.0
+		if idx := strings.IndexByte(part, ';'); idx >= 0 {
+			value = strings.TrimSpace(part[:idx])
+			for _, param := range strings.Split(part[idx+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		specs = append(specs, acceptSpec{value: strings.ToLower(value), q: q})
+	}
+	sort.SliceStable(specs, func(a, b int) bool {
+		return specs[a].q > specs[b].q
+	})
+	return specs
+}
+
+// Accepts returns the first offer the client's Accept header allows,
+// checked in the client's order of preference, or "" when none is
+// acceptable. Offers are media types, bare extensions work too:
+//
+//	c.Accepts("application/json", "text/html")
+//	c.Accepts("json", "html")
+//
+// A request without an Accept header accepts everything, the first offer
+// wins then
+func (c *Ctx) Accepts(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	header := c.Header("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	for _, spec := range parseAccept(header) {
+		for _, offer := range offers {
+			if matchMediaType(spec.value, normalizeOffer(offer)) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// AcceptsCharsets returns the first offered charset the Accept-Charset
+// header allows, or "" when none is acceptable
+func (c *Ctx) AcceptsCharsets(offers ...string) string {
+	return acceptsSimple(c.Header("Accept-Charset"), offers)
+}
+
+// AcceptsEncodings returns the first offered encoding the Accept-Encoding
+// header allows, or "" when none is acceptable:
+//
+//	c.AcceptsEncodings("br", "gzip")
+func (c *Ctx) AcceptsEncodings(offers ...string) string {
+	return acceptsSimple(c.Header("Accept-Encoding"), offers)
+}
+
+// acceptsSimple matches offers against a header whose entries are plain
+// tokens rather than media types, Eg charsets and encodings
+func acceptsSimple(header string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if header == "" {
+		return offers[0]
+	}
+	for _, spec := range parseAccept(header) {
+		for _, offer := range offers {
+			if spec.value == "*" || spec.value == strings.ToLower(offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// matchMediaType reports whether the accepted media type covers the
+// offered one, */* covers everything and text/* covers every text type
+func matchMediaType(accepted, offer string) bool {
+	if accepted == "*/*" || accepted == offer {
+		return true
+	}
+	if strings.HasSuffix(accepted, "/*") {
+		return strings.HasPrefix(offer, accepted[:len(accepted)-1])
+	}
+	return false
+}
+
+// normalizeOffer turns a bare extension like "json" into its media type
+// so Accepts can be called with either form
+func normalizeOffer(offer string) string {
+	offer = strings.ToLower(offer)
+	if strings.IndexByte(offer, '/') >= 0 {
+		return offer
+	}
+	if mediaType := mime.TypeByExtension("." + offer); mediaType != "" {
+		if idx := strings.IndexByte(mediaType, ';'); idx >= 0 {
+			mediaType = mediaType[:idx]
+		}
+		return strings.ToLower(mediaType)
+	}
+	return offer
+}
@@ -0,0 +1,58 @@
+package pine
+
+import (
+	"testing"
+)
+
+func TestAccepts_QValueOrdering(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("Accept", "application/json;q=0.9, text/html")
+
+	if got := ctx.Accepts("application/json", "text/html"); got != "text/html" {
+		t.Fatalf("expected 'text/html', got '%s'", got)
+	}
+}
+
+func TestAccepts_Wildcards(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("Accept", "text/*;q=0.5, */*;q=0.1")
+
+	if got := ctx.Accepts("application/json", "text/plain"); got != "text/plain" {
+		t.Fatalf("expected 'text/plain', got '%s'", got)
+	}
+}
+
+func TestAccepts_Extension(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("Accept", "application/json")
+
+	if got := ctx.Accepts("html", "json"); got != "json" {
+		t.Fatalf("expected 'json', got '%s'", got)
+	}
+}
+
+func TestAccepts_NoneAcceptable(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("Accept", "text/html, application/xml;q=0")
+
+	if got := ctx.Accepts("application/xml"); got != "" {
+		t.Fatalf("expected no match, got '%s'", got)
+	}
+}
+
+func TestAcceptsEncodings(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("Accept-Encoding", "gzip;q=0.8, br")
+
+	if got := ctx.AcceptsEncodings("gzip", "br"); got != "br" {
+		t.Fatalf("expected 'br', got '%s'", got)
+	}
+}
+
+func TestAcceptsCharsets_MissingHeader(t *testing.T) {
+	ctx := Mock_Ctx()
+
+	if got := ctx.AcceptsCharsets("utf-8", "iso-8859-1"); got != "utf-8" {
+		t.Fatalf("expected 'utf-8', got '%s'", got)
+	}
+}
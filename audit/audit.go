@@ -0,0 +1,235 @@
+// Pine's audit log subsystem
+// Compliance-sensitive apps must record who did what to what. Handlers
+// record structured events and each event is chained to the previous one
+// through a hash, so tampering with a stored event breaks every hash that
+// follows it and is detectable with Verify
+//
+//	audit := audit.New(audit.Config{FilePath: "audit.jsonl"})
+//	app.Use(audit.Middleware())
+//	audit.RegisterAdmin(app, "/admin/audit")
+//
+//	func deleteUser(c *pine.Ctx) error {
+//		audit.From(c).Record("admin@corp", "user.delete", c.Params("id"))
+//		...
+//	}
+
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Event is a single audit record. The hash covers the event's own fields
+// and the hash of the previous event, forming a tamper-evident chain
+type Event struct {
+	ID       int64                  `json:"id"`
+	Time     time.Time              `json:"time"`
+	Actor    string                 `json:"actor"`
+	Action   string                 `json:"action"`
+	Target   string                 `json:"target"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	PrevHash string                 `json:"prev_hash"`
+	Hash     string                 `json:"hash"`
+}
+
+type Config struct {
+	// FilePath appends every event to a JSONL file as well so the trail
+	// survives restarts
+	//
+	// Default: "" which keeps events in memory only
+	FilePath string
+}
+
+// Log holds the chained events. Create one with New and share it across
+// handlers through Middleware
+type Log struct {
+	mu       sync.Mutex
+	events   []Event
+	lastHash string
+	file     *os.File
+}
+
+// LocalsKey is the locals key Middleware stores the log under
+const LocalsKey = "audit"
+
+// ErrChainBroken is returned by Verify when an event's hash does not match
+// its contents, meaning the trail was tampered with
+var ErrChainBroken = errors.New("audit: hash chain broken")
+
+func New(config ...Config) *Log {
+	l := &Log{}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 && config[0].FilePath != "" {
+		path := config[0].FilePath
+
+		// continue the trail a previous run persisted, otherwise every
+		// restart would begin a new chain with overlapping IDs that can
+		// never be verified
+		if err := l.load(path); err != nil {
+			logger.RuntimeError("audit: could not load trail from " + path + ": " + err.Error())
+			pine.NotifyError(err, "audit")
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			// a compliance log that cannot persist must not fail silently
+			logger.RuntimeError("audit: could not open " + path + ": " + err.Error())
+			pine.NotifyError(err, "audit")
+			return l
+		}
+		l.file = file
+	}
+	return l
+}
+
+// load reads the events a previous run appended to path into memory so
+// Record continues the chain where it left off and Verify covers the
+// stored trail as well
+func (l *Log) load(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		l.events = append(l.events, event)
+		l.lastHash = event.Hash
+	}
+	return nil
+}
+
+// Record appends an event to the trail. Details are optional and carry
+// anything worth keeping alongside the actor, action and target
+func (l *Log) Record(actor, action, target string, details ...map[string]interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event := Event{
+		ID:       int64(len(l.events) + 1),
+		Time:     time.Now().UTC(),
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		PrevHash: l.lastHash,
+	}
+	if len(details) > 0 {
+		event.Details = details[0]
+	}
+	event.Hash = hash(event)
+
+	l.events = append(l.events, event)
+	l.lastHash = event.Hash
+
+	if l.file != nil {
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := l.file.Write(append(raw, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events returns a snapshot of the trail, optionally filtered by actor,
+// action and target. Empty filters match everything
+func (l *Log) Events(actor, action, target string) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	matched := make([]Event, 0, len(l.events))
+	for _, event := range l.events {
+		if actor != "" && event.Actor != actor {
+			continue
+		}
+		if action != "" && event.Action != action {
+			continue
+		}
+		if target != "" && event.Target != target {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	return matched
+}
+
+// Verify walks the chain and reports the first tampered event, a nil error
+// means the whole trail is intact
+func (l *Log) Verify() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev := ""
+	for _, event := range l.events {
+		if event.PrevHash != prev || event.Hash != hash(event) {
+			return fmt.Errorf("%w at event %d", ErrChainBroken, event.ID)
+		}
+		prev = event.Hash
+	}
+	return nil
+}
+
+// Middleware stores the log in the locals so handlers can reach it with
+// From without a package level variable
+func (l *Log) Middleware() pine.Middleware {
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			c.Locals(LocalsKey, l)
+			return next(c)
+		}
+	}
+}
+
+// From fetches the log Middleware stored for this request, nil when the
+// middleware is not registered
+func From(c *pine.Ctx) *Log {
+	l, _ := c.Locals(LocalsKey).(*Log)
+	return l
+}
+
+// RegisterAdmin exposes the trail for querying,
+// GET path lists events filtered by the actor, action and target query
+// parameters and GET path/verify checks the chain. Guard these routes with
+// your auth middleware
+func (l *Log) RegisterAdmin(server *pine.Server, path string) {
+	server.Get(path, func(c *pine.Ctx) error {
+		return c.JSON(l.Events(c.Query("actor"), c.Query("action"), c.Query("target")))
+	})
+	server.Get(path+"/verify", func(c *pine.Ctx) error {
+		if err := l.Verify(); err != nil {
+			return c.JSON(map[string]string{"status": "broken", "error": err.Error()}, 500)
+		}
+		return c.JSON(map[string]string{"status": "intact"})
+	})
+}
+
+// hash computes an event's chained hash, the Hash field itself is excluded
+func hash(event Event) string {
+	event.Hash = ""
+	raw, _ := json.Marshal(event)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,145 @@
+// Pine's implementation of authorization
+// This is a middleware that enforces the scopes a route declares with
+// RequireScope against the claims of the caller. Claims are expected in the
+// locals of the request, put there by your authentication middleware after
+// verifying a session or token
+//
+// The decision itself is made by a Policy so that anything from simple scope
+// matching to a full policy engine can be plugged in
+
+package authz
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Policy decides whether a request may proceed.
+// required holds the scopes the route declared and claims is whatever your
+// authentication middleware stored in the locals
+type Policy interface {
+	Allow(c *pine.Ctx, required []string, claims interface{}) bool
+}
+
+type Config struct {
+	// Defines the locals key the caller's claims are read from
+	//
+	// Default: "claims"
+	ClaimsKey string
+
+	// Policy makes the authorization decision
+	//
+	// Default: ScopePolicy
+	Policy Policy
+
+	// Defines the handler that will be called when a request is denied
+	//
+	// Default: returns a 403 status code
+	Handler pine.Handler
+}
+
+func defaultHandler(c *pine.Ctx) error {
+	return c.SendStatus(http.StatusForbidden)
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		ClaimsKey: "claims",
+		Policy:    ScopePolicy{},
+		Handler:   defaultHandler,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.ClaimsKey != "" {
+			cfg.ClaimsKey = userConfig.ClaimsKey
+		}
+		if userConfig.Policy != nil {
+			cfg.Policy = userConfig.Policy
+		}
+		if userConfig.Handler != nil {
+			cfg.Handler = userConfig.Handler
+		}
+	}
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			required := requiredScopes(c)
+			// routes without declared scopes are public
+			if len(required) == 0 {
+				return next(c)
+			}
+
+			claims := c.Locals(cfg.ClaimsKey)
+			if !cfg.Policy.Allow(c, required, claims) {
+				return cfg.Handler(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// requiredScopes reads the scopes the matched route declared
+func requiredScopes(c *pine.Ctx) []string {
+	route := c.Route()
+	if route == nil {
+		return nil
+	}
+	if scopes, ok := route.Meta(pine.ScopesMetaKey).([]string); ok {
+		return scopes
+	}
+	return nil
+}
+
+// ScopePolicy is the default policy. It extracts the scopes the caller
+// holds from the claims and allows the request when every required scope is
+// present
+//
+// Claims can be a []string of scopes, a space delimited string the way
+// OAuth tokens carry them, or a map with a "scope" or "scopes" entry
+type ScopePolicy struct{}
+
+func (ScopePolicy) Allow(c *pine.Ctx, required []string, claims interface{}) bool {
+	held := heldScopes(claims)
+	if len(held) == 0 {
+		return false
+	}
+	for _, want := range required {
+		if _, ok := held[want]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func heldScopes(claims interface{}) map[string]struct{} {
+	held := make(map[string]struct{})
+	switch v := claims.(type) {
+	case []string:
+		for _, scope := range v {
+			held[scope] = struct{}{}
+		}
+	case string:
+		for _, scope := range strings.Fields(v) {
+			held[scope] = struct{}{}
+		}
+	case map[string]interface{}:
+		if inner, ok := v["scopes"]; ok {
+			return heldScopes(inner)
+		}
+		if inner, ok := v["scope"]; ok {
+			return heldScopes(inner)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if scope, ok := item.(string); ok {
+				held[scope] = struct{}{}
+			}
+		}
+	}
+	return held
+}
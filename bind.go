@@ -3,8 +3,12 @@ package pine
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -15,6 +19,27 @@ var (
 	ErrValidation = errors.New("bind: validation failed")
 )
 
+// ValidationErrors carries every field that failed validation rather than
+// just the first, keyed by the field's JSON name. The central error handler
+// renders this as a field to message map so clients can show all form
+// errors at once
+type ValidationErrors map[string]string
+
+func (ve ValidationErrors) Error() string {
+	fields := make([]string, 0, len(ve))
+	for field := range ve {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return "bind: validation failed for " + strings.Join(fields, ", ")
+}
+
+// Is keeps errors.Is(err, ErrValidation) working for callers that do not
+// care about the individual fields
+func (ve ValidationErrors) Is(target error) bool {
+	return target == ErrValidation
+}
+
 // BindJSON binds the request body to the given interface.
 // You can use this to validate the request body without adding further logic
 // to your handlers.
@@ -26,7 +51,110 @@ func (c *Ctx) BindJSON(v interface{}) error {
 	if err != nil {
 		return ErrParse
 	}
-	return bindData(v)
+	msg := requiredMessage
+	if c.Server != nil && c.Server.config.Messages.FieldRequired != "" {
+		msg = c.Server.config.Messages.FieldRequired
+	}
+	return bindData(v, msg)
+}
+
+// BindBody binds the request body to v using the codec registered for the
+// request's Content-Type, see RegisterCodec. Requests without a matching
+// codec fall back to JSON, so handlers written with BindBody keep working
+// for JSON clients. The same required field validation as BindJSON runs
+// at the end
+func (c *Ctx) BindBody(v interface{}) error {
+	codec, ok := lookupCodec(c.Header("Content-Type"))
+	if !ok {
+		return c.BindJSON(v)
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ErrParse
+	}
+	if err := codec.Unmarshal(raw, v); err != nil {
+		return ErrParse
+	}
+	msg := requiredMessage
+	if c.Server != nil && c.Server.config.Messages.FieldRequired != "" {
+		msg = c.Server.config.Messages.FieldRequired
+	}
+	return bindData(v, msg)
+}
+
+// BindAll fills one struct from every part of the request in a single call,
+// made for endpoints like PUT /users/:id where the id lives in the path and
+// the rest in the body. Fields are matched by tags:
+//
+//	type UpdateUser struct {
+//		ID    int    `param:"id"`
+//		Force bool   `query:"force"`
+//		Trace string `header:"X-Trace-Id"`
+//		Name  string `json:"name"`
+//	}
+//
+// The JSON body is decoded first, then query values, then headers and
+// finally path params, so the more specific source always wins when two
+// carry the same field. The same required field validation as BindJSON runs
+// at the end
+func (c *Ctx) BindAll(destination interface{}) error {
+	typ := reflect.TypeOf(destination)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return ErrPtr
+	}
+
+	// the body is the least specific source and goes first, decoded by
+	// the codec registered for the request's Content-Type when one exists
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if codec, ok := lookupCodec(c.Header("Content-Type")); ok {
+			raw, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				return ErrParse
+			}
+			if err := codec.Unmarshal(raw, destination); err != nil {
+				return ErrParse
+			}
+		} else if err := json.NewDecoder(c.Request.Body).Decode(destination); err != nil {
+			return ErrParse
+		}
+	}
+
+	val := reflect.ValueOf(destination).Elem()
+	structType := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := structType.Field(i)
+		target := val.Field(i)
+		if !target.CanSet() {
+			continue
+		}
+		if tag := field.Tag.Get("query"); tag != "" {
+			if raw := c.Query(tag); raw != "" {
+				if err := bind(raw, target.Addr().Interface()); err != nil {
+					return err
+				}
+			}
+		}
+		if tag := field.Tag.Get("header"); tag != "" {
+			if raw := c.Header(tag); raw != "" {
+				if err := bind(raw, target.Addr().Interface()); err != nil {
+					return err
+				}
+			}
+		}
+		if tag := field.Tag.Get("param"); tag != "" {
+			if raw := c.Params(tag); raw != "" {
+				if err := bind(raw, target.Addr().Interface()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	msg := requiredMessage
+	if c.Server != nil && c.Server.config.Messages.FieldRequired != "" {
+		msg = c.Server.config.Messages.FieldRequired
+	}
+	return bindData(destination, msg)
 }
 
 // BindParam binds the specified parameter value of a request.
@@ -94,17 +222,27 @@ func bind(input string, destination interface{}) error {
 // Called to the bind of the JSON body
 // A future revision of this will be implemented to handle forms and XML bodies
 // but the logic will pretty much be the same
-func bindData(destination interface{}) error {
+// requiredMessage is the default per field validation message, it can be
+// translated through Config.Messages.FieldRequired
+const requiredMessage = "is required"
+
+func bindData(destination interface{}, requiredMsg ...string) error {
+	msg := requiredMessage
+	if len(requiredMsg) > 0 && requiredMsg[0] != "" {
+		msg = requiredMsg[0]
+	}
+
 	v := reflect.ValueOf(destination)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
+	failed := ValidationErrors{}
 	// we can check if the value is a struct or a slice
 	if v.Kind() == reflect.Struct {
 		for i := 0; i < v.NumField(); i++ {
 			field := v.Field(i)
 			if isZeroValue(field) {
-				return ErrValidation
+				failed[fieldName(v.Type().Field(i))] = msg
 			}
 		}
 	}
@@ -112,13 +250,31 @@ func bindData(destination interface{}) error {
 		length := v.Len()
 		for i := 0; i < length; i++ {
 			if isZeroValue(v.Index(i)) {
-				return ErrValidation
+				failed[fmt.Sprintf("[%d]", i)] = msg
 			}
 		}
 	}
+	if len(failed) > 0 {
+		return failed
+	}
 	return nil
 }
 
+// fieldName resolves the name clients know a struct field by, the JSON tag
+// when one is set and the Go field name otherwise
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag != "" && tag != "-" {
+		if comma := strings.Index(tag, ","); comma != -1 {
+			tag = tag[:comma]
+		}
+		if tag != "" {
+			return tag
+		}
+	}
+	return field.Name
+}
+
 // Internal helper function to check if the value is zero
 // Returns true if the value is zero and hence handled as an error since
 // the unpacked value is not set
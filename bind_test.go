@@ -89,3 +89,76 @@ func TestBindQuery_NotFound(t *testing.T) {
 		t.Fatalf("expected ErrValidation, got %v", err)
 	}
 }
+
+func TestBindJSON_ValidationErrors(t *testing.T) {
+	body := `{"name": "", "age": 0}`
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	ctx := &Ctx{Request: req}
+
+	var data struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	err := ctx.BindJSON(&data)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected ErrValidation, got %v", err)
+	}
+
+	var ve ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	if len(ve) != 2 {
+		t.Fatalf("expected 2 field errors, got %d: %v", len(ve), ve)
+	}
+	if _, ok := ve["name"]; !ok {
+		t.Errorf("expected an error for field name, got %v", ve)
+	}
+	if _, ok := ve["age"]; !ok {
+		t.Errorf("expected an error for field age, got %v", ve)
+	}
+}
+
+func TestBindAll(t *testing.T) {
+	body := `{"name": "John", "id": 1}`
+	req := httptest.NewRequest(http.MethodPut, "/users/42?force=true", bytes.NewBufferString(body))
+	req.Header.Set("X-Trace-Id", "abc123")
+	ctx := &Ctx{
+		Request: req,
+		params:  map[string]string{"id": "42"},
+	}
+
+	var data struct {
+		ID    int    `param:"id" json:"id"`
+		Force bool   `query:"force"`
+		Trace string `header:"X-Trace-Id"`
+		Name  string `json:"name"`
+	}
+
+	err := ctx.BindAll(&data)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if data.ID != 42 {
+		t.Errorf("expected the path param to win over the body, got %d", data.ID)
+	}
+	if !data.Force {
+		t.Error("expected Force to be bound from the query")
+	}
+	if data.Trace != "abc123" {
+		t.Errorf("expected Trace to be bound from the header, got %q", data.Trace)
+	}
+	if data.Name != "John" {
+		t.Errorf("expected Name to be bound from the body, got %q", data.Name)
+	}
+}
+
+func TestBindAll_NotAStruct(t *testing.T) {
+	ctx := Mock_Ctx()
+
+	var value string
+	if err := ctx.BindAll(&value); !errors.Is(err, ErrPtr) {
+		t.Fatalf("expected ErrPtr, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Pine's implementation of bot and user agent filtering
+// This is a middleware that classifies the User-Agent of every request as a
+// browser, crawler, preview bot or CLI tool. The classification is stored in
+// the locals of the request so that handlers and access logs can read it and
+// selected classes can be blocked outright
+
+package botfilter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Classes assigned to a request based on its User-Agent
+const (
+	ClassBrowser = "browser"
+	ClassCrawler = "crawler"
+	ClassPreview = "preview"
+	ClassCLI     = "cli"
+	ClassUnknown = "unknown"
+)
+
+// substrings looked for in a lower cased User-Agent, checked in order.
+// Preview bots are checked before crawlers since most of them also contain
+// the word "bot"
+var previewAgents = []string{
+	"facebookexternalhit",
+	"twitterbot",
+	"slackbot",
+	"discordbot",
+	"telegrambot",
+	"linkedinbot",
+	"whatsapp",
+}
+
+var crawlerAgents = []string{
+	"googlebot",
+	"bingbot",
+	"yandex",
+	"baiduspider",
+	"duckduckbot",
+	"spider",
+	"crawler",
+	"bot",
+}
+
+var cliAgents = []string{
+	"curl",
+	"wget",
+	"httpie",
+	"python-requests",
+	"go-http-client",
+	"postmanruntime",
+}
+
+type Config struct {
+	// Defines the classes that will be blocked.
+	// Eg: []string{botfilter.ClassCrawler, botfilter.ClassCLI}
+	//
+	// Default: []string{}
+	Block []string
+
+	// Defines the handler that will be called when a blocked class makes
+	// a request
+	//
+	// Default: returns a 403 status code
+	Handler pine.Handler
+
+	// Defines the locals key the classification is stored under
+	//
+	// Default: "ua_class"
+	LocalsKey string
+
+	// Custom maps additional User-Agent substrings to a class and is
+	// checked before the built in classification
+	// Eg: map[string]string{"mycompany-healthcheck": botfilter.ClassCLI}
+	//
+	// Default: map[string]string{}
+	Custom map[string]string
+
+	// When set to true the classification is also written to the
+	// X-UA-Class response header so that it shows up in access logs and
+	// upstream proxies
+	//
+	// Default: false
+	TagResponse bool
+
+	// Internal map for fast blocked class look up.
+	internalBlock map[string]struct{}
+}
+
+func defaultHandler(c *pine.Ctx) error {
+	return c.SendStatus(http.StatusForbidden)
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		Block:         []string{},
+		Handler:       defaultHandler,
+		LocalsKey:     "ua_class",
+		Custom:        map[string]string{},
+		internalBlock: make(map[string]struct{}),
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Block != nil {
+			for _, class := range userConfig.Block {
+				cfg.internalBlock[class] = struct{}{}
+			}
+		}
+		if userConfig.Handler != nil {
+			cfg.Handler = userConfig.Handler
+		}
+		if userConfig.LocalsKey != "" {
+			cfg.LocalsKey = userConfig.LocalsKey
+		}
+		if userConfig.Custom != nil {
+			cfg.Custom = userConfig.Custom
+		}
+		if userConfig.TagResponse {
+			cfg.TagResponse = userConfig.TagResponse
+		}
+	}
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			class := cfg.classify(c.Header("User-Agent"))
+
+			// store the classification so that handlers and access logs
+			// further down the stack can read it with c.Locals
+			c.Locals(cfg.LocalsKey, class)
+
+			if cfg.TagResponse {
+				c.Set("X-UA-Class", class)
+			}
+
+			if _, blocked := cfg.internalBlock[class]; blocked {
+				return cfg.Handler(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Classify returns the class of a User-Agent string using the built in
+// classification only. Useful if you want to classify agents outside of
+// the middleware
+func Classify(userAgent string) string {
+	cfg := Config{Custom: map[string]string{}}
+	return cfg.classify(userAgent)
+}
+
+func (cfg *Config) classify(userAgent string) string {
+	if userAgent == "" {
+		return ClassUnknown
+	}
+	agent := strings.ToLower(userAgent)
+
+	for pattern, class := range cfg.Custom {
+		if strings.Contains(agent, strings.ToLower(pattern)) {
+			return class
+		}
+	}
+	for _, pattern := range previewAgents {
+		if strings.Contains(agent, pattern) {
+			return ClassPreview
+		}
+	}
+	for _, pattern := range crawlerAgents {
+		if strings.Contains(agent, pattern) {
+			return ClassCrawler
+		}
+	}
+	for _, pattern := range cliAgents {
+		if strings.Contains(agent, pattern) {
+			return ClassCLI
+		}
+	}
+	return ClassBrowser
+}
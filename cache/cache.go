@@ -3,6 +3,16 @@ package cache
 import (
 	"sync"
 	"time"
+
+	"github.com/BryanMwangi/pine/metrics"
+)
+
+// counters exported through the metrics package so cache health is
+// visible at runtime
+var (
+	hits      = metrics.NewCounter("cache_hits")
+	misses    = metrics.NewCounter("cache_misses")
+	evictions = metrics.NewCounter("cache_evictions")
 )
 
 // Cache is a simple in memory cache that stores data in a map in memory.
@@ -71,8 +81,10 @@ func (c *Cache) Get(key string) interface{} {
 	defer c.mu.RUnlock()
 	val, ok := c.data[key]
 	if !ok || val.exp < time.Now().Unix() {
+		misses.Inc()
 		return nil
 	}
+	hits.Inc()
 	return val.data
 }
 
@@ -127,6 +139,7 @@ func (c *Cache) start() {
 			// remove expired data
 			if v.exp < now {
 				delete(c.data, k)
+				evictions.Inc()
 			}
 		}
 
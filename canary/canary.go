@@ -0,0 +1,157 @@
+// Pine's canary deployment proxy
+// New builds a handler that forwards a configurable share of traffic to a
+// canary upstream while the rest goes to the stable one. The canary's
+// error rate is tracked through the metrics package and once it crosses
+// the threshold the canary is rolled back automatically, all traffic then
+// flows to the stable upstream until Reset is called
+//
+//	proxy := canary.New(canary.Config{
+//		Stable:     "http://app-v1.internal",
+//		Canary:     "http://app-v2.internal",
+//		Percentage: 10,
+//	})
+//	app.Get("/*", proxy.Handler())
+
+package canary
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/BryanMwangi/pine/metrics"
+)
+
+type Config struct {
+	// Stable is the URL of the upstream serving the current release
+	Stable string
+
+	// Canary is the URL of the upstream serving the candidate release
+	Canary string
+
+	// Percentage is the share of requests sent to the canary, 0 to 100
+	//
+	// Default: 5
+	Percentage int
+
+	// ErrorThreshold rolls the canary back once its share of failed
+	// requests exceeds this ratio
+	//
+	// Default: 0.5
+	ErrorThreshold float64
+
+	// MinSamples is how many canary requests must complete before the
+	// error rate is evaluated, so one early failure cannot roll back
+	//
+	// Default: 20
+	MinSamples int64
+}
+
+// Proxy shifts traffic between the two upstreams, create one with New
+type Proxy struct {
+	cfg    Config
+	stable *httputil.ReverseProxy
+	canary *httputil.ReverseProxy
+
+	requests *metrics.Counter
+	errors   *metrics.Counter
+
+	rolledBack atomic.Bool
+}
+
+func New(config Config) *Proxy {
+	if config.Percentage <= 0 {
+		config.Percentage = 5
+	}
+	if config.ErrorThreshold <= 0 {
+		config.ErrorThreshold = 0.5
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 20
+	}
+
+	stableURL, err := url.Parse(config.Stable)
+	if err != nil {
+		logger.RuntimeError("invalid stable upstream: " + err.Error())
+	}
+	canaryURL, err := url.Parse(config.Canary)
+	if err != nil {
+		logger.RuntimeError("invalid canary upstream: " + err.Error())
+	}
+
+	p := &Proxy{
+		cfg:      config,
+		stable:   httputil.NewSingleHostReverseProxy(stableURL),
+		canary:   httputil.NewSingleHostReverseProxy(canaryURL),
+		requests: metrics.NewCounter("canary_requests"),
+		errors:   metrics.NewCounter("canary_errors"),
+	}
+
+	// failed canary responses feed the rollback decision
+	p.canary.ModifyResponse = func(resp *http.Response) error {
+		p.observe(resp.StatusCode >= 500)
+		return nil
+	}
+	p.canary.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		p.observe(true)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return p
+}
+
+// Handler proxies the request to the canary or the stable upstream
+func (p *Proxy) Handler() pine.Handler {
+	return func(c *pine.Ctx) error {
+		if p.useCanary() {
+			c.Set("X-Canary", "true")
+			p.canary.ServeHTTP(c.Response.ResponseWriter, c.Request)
+			return nil
+		}
+		p.stable.ServeHTTP(c.Response.ResponseWriter, c.Request)
+		return nil
+	}
+}
+
+// RolledBack reports whether the canary has been taken out of rotation
+func (p *Proxy) RolledBack() bool {
+	return p.rolledBack.Load()
+}
+
+// Reset puts the canary back in rotation and clears its counters, call
+// this after deploying a fixed canary build
+func (p *Proxy) Reset() {
+	p.requests.Add(-p.requests.Value())
+	p.errors.Add(-p.errors.Value())
+	p.rolledBack.Store(false)
+}
+
+// useCanary rolls the traffic split, always false after a rollback
+func (p *Proxy) useCanary() bool {
+	if p.rolledBack.Load() {
+		return false
+	}
+	return rand.Intn(100) < p.cfg.Percentage
+}
+
+// observe records one canary outcome and evaluates the rollback threshold
+func (p *Proxy) observe(failed bool) {
+	p.requests.Inc()
+	if failed {
+		p.errors.Inc()
+	}
+
+	requests := p.requests.Value()
+	if requests < p.cfg.MinSamples {
+		return
+	}
+	rate := float64(p.errors.Value()) / float64(requests)
+	if rate > p.cfg.ErrorThreshold && !p.rolledBack.Swap(true) {
+		logger.RuntimeError("canary rolled back, error rate " +
+			strconv.FormatFloat(rate, 'f', 2, 64) + " exceeded threshold")
+	}
+}
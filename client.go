@@ -2,6 +2,7 @@ package pine
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -23,13 +24,26 @@ type Client struct {
 	*http.Client
 	req *Request
 	res *http.Response
+
+	// middleware wrapped around outgoing requests, see Use
+	middleware []ClientMiddleware
 }
 
+// RoundTripFunc executes one outgoing HTTP request, the client middleware
+// chain is built from these
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// ClientMiddleware wraps a RoundTripFunc the same way server middleware
+// wraps handlers, use it for cross-cutting concerns on outgoing requests
+// such as auth token injection, logging, metrics or tracing
+type ClientMiddleware func(next RoundTripFunc) RoundTripFunc
+
 type Request struct {
 	http.Request
 	body        *bytes.Buffer
 	uri         string
 	method      string
+	ctx         context.Context
 	jsonEncoder JSONMarshal
 }
 
@@ -56,6 +70,9 @@ func NewClient() *Client {
 func NewClientWithTimeout(timeout time.Duration) *Client {
 	return &Client{
 		Client: &http.Client{Timeout: timeout},
+		req: &Request{
+			jsonEncoder: json.Marshal,
+		},
 	}
 }
 
@@ -63,6 +80,38 @@ func (c *Client) Request() *Request {
 	return c.req
 }
 
+// Use appends middleware to the client, every outgoing request flows
+// through the chain in the order it was added:
+//
+//	client.Use(func(next pine.RoundTripFunc) pine.RoundTripFunc {
+//		return func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("Authorization", "Bearer "+token())
+//			return next(req)
+//		}
+//	})
+func (c *Client) Use(middleware ...ClientMiddleware) {
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// do runs a request through the middleware chain and the underlying client
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	// forward whatever deadline budget is left so downstream services can
+	// give up in time as well
+	if deadline, ok := req.Context().Deadline(); ok && req.Header.Get(HeaderRequestTimeout) == "" {
+		if remaining := time.Until(deadline); remaining > 0 {
+			req.Header.Set(HeaderRequestTimeout, remaining.String())
+		}
+	}
+
+	send := func(req *http.Request) (*http.Response, error) {
+		return c.Client.Do(req)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		send = c.middleware[i](send)
+	}
+	return send(req)
+}
+
 // Sets the body of the request as JSON
 func (r *Request) JSON(body interface{}) error {
 	raw, err := r.jsonEncoder(body)
@@ -80,6 +129,35 @@ func (r *Request) JSON(body interface{}) error {
 	return nil
 }
 
+// Body sets the request body using the codec registered for contentType,
+// the non JSON sibling of Request.JSON, see RegisterCodec:
+//
+//	request.Body(payload, "application/cbor")
+func (r *Request) Body(body interface{}, contentType string) error {
+	codec, ok := lookupCodec(contentType)
+	if !ok {
+		return ErrNoCodec
+	}
+	raw, err := codec.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	r.Header.Set("Content-Type", contentType)
+	r.body = bytes.NewBuffer(raw)
+	return nil
+}
+
+// RawBody sets the request body to bytes that are already encoded,
+// useful when forwarding a body that was received rather than built.
+// The Content-Type header is left for the caller to set
+func (r *Request) RawBody(body []byte) *Request {
+	r.body = bytes.NewBuffer(body)
+	return r
+}
+
 // Use this to set the headers of the request
 // You can add as many headers as you want in a map
 //
@@ -117,6 +195,19 @@ func (r *Request) SetMethod(method string) *Request {
 	return r
 }
 
+// WithContext attaches a context to the request so cancellation and
+// deadlines flow into the outgoing call. Pass the request context of the
+// handler making the call:
+//
+//	request.WithContext(c.Context())
+//
+// When the context carries a deadline the remaining budget is forwarded in
+// the X-Request-Timeout header so downstream Pine services stop early too
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
 // Use this method to skip TLS verification
 // This can be useful if the api you are calling has outdated TLS certificates
 func (c *Client) SetTLSVerification(skip bool) {
@@ -143,10 +234,14 @@ func (c *Client) SendRequest() error {
 	var req *http.Request
 	var err error
 
+	ctx := c.req.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	if c.req.body == nil {
-		req, err = http.NewRequest(c.req.method, c.req.uri, nil)
+		req, err = http.NewRequestWithContext(ctx, c.req.method, c.req.uri, nil)
 	} else {
-		req, err = http.NewRequest(c.req.method, c.req.uri, c.req.body)
+		req, err = http.NewRequestWithContext(ctx, c.req.method, c.req.uri, c.req.body)
 	}
 
 	if err != nil {
@@ -157,7 +252,7 @@ func (c *Client) SendRequest() error {
 	}
 	c.req.Request = *req
 
-	res, err := c.Client.Do(&c.req.Request)
+	res, err := c.do(&c.req.Request)
 	if err != nil {
 		return err
 	}
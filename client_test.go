@@ -169,3 +169,91 @@ func TestClient_ReadResponse_NoResponse(t *testing.T) {
 		t.Fatalf("expected ErrResponseIsNil, got %v", err)
 	}
 }
+
+func TestClient_Use(t *testing.T) {
+	// Setup a test server that echoes a header set by middleware
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo", r.Header.Get("X-Injected"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	order := []string{}
+	client := NewClient()
+	client.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "first")
+			req.Header.Set("X-Injected", "middleware")
+			return next(req)
+		}
+	})
+	client.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			order = append(order, "second")
+			return next(req)
+		}
+	})
+
+	req := client.Request()
+	req.SetRequestURI(ts.URL).SetMethod("GET")
+
+	err := client.SendRequest()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if client.res.Header.Get("X-Echo") != "middleware" {
+		t.Fatal("expected the injected header to reach the server")
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("expected middleware to run in order, got %v", order)
+	}
+}
+
+func TestClient_VCR(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("recorded"))
+	}))
+	defer ts.Close()
+
+	fixture := t.TempDir() + "/cassette.json"
+
+	// record a real interaction
+	recorder := NewClient()
+	if err := recorder.VCR(fixture, VCRRecord); err != nil {
+		t.Fatalf("expected no error installing the recorder, got %v", err)
+	}
+	recorder.Request().SetRequestURI(ts.URL).SetMethod("GET")
+	if err := recorder.SendRequest(); err != nil {
+		t.Fatalf("expected no error recording, got %v", err)
+	}
+	recorder.ReadResponse()
+
+	// replay it without touching the network
+	replayer := NewClient()
+	if err := replayer.VCR(fixture, VCRReplay); err != nil {
+		t.Fatalf("expected no error installing the replayer, got %v", err)
+	}
+	replayer.Request().SetRequestURI(ts.URL).SetMethod("GET")
+	if err := replayer.SendRequest(); err != nil {
+		t.Fatalf("expected no error replaying, got %v", err)
+	}
+	code, body, err := replayer.ReadResponse()
+	if err != nil {
+		t.Fatalf("expected no error reading the replayed response, got %v", err)
+	}
+	if code != http.StatusOK || string(body) != "recorded" {
+		t.Fatalf("expected the recorded response, got %d %q", code, body)
+	}
+	if hits != 1 {
+		t.Fatalf("expected the server to be hit once, got %d", hits)
+	}
+
+	// a second replay of the same interaction has nothing left to serve
+	replayer.Request().SetRequestURI(ts.URL).SetMethod("GET")
+	if err := replayer.SendRequest(); !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("expected ErrNoFixture, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// how long to wait after a file change before rebuilding so that editors
+// that write multiple files in a row only trigger a single rebuild
+const debounce = 500 * time.Millisecond
+
+// runDev watches the Go source files of the app in dir, rebuilds it on every
+// change and restarts the binary. The previous process is asked to shut down
+// with SIGTERM first so that graceful shutdown hooks still run
+func runDev(args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// watch every directory of the project except hidden ones
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") && d.Name() != "." {
+				return filepath.SkipDir
+			}
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	binary := filepath.Join(os.TempDir(), "pine-dev-app")
+	var app *exec.Cmd
+
+	restart := func() {
+		if app != nil && app.Process != nil {
+			// hand the listener over by letting the old process shut down
+			// gracefully before the new one binds the address
+			app.Process.Signal(os.Interrupt)
+			app.Wait()
+		}
+
+		logger.RuntimeInfo("building...")
+		build := exec.Command("go", "build", "-o", binary, ".")
+		build.Dir = dir
+		build.Stdout = os.Stdout
+		build.Stderr = os.Stderr
+		if err := build.Run(); err != nil {
+			logger.RuntimeError("build failed, waiting for next change")
+			app = nil
+			return
+		}
+
+		app = exec.Command(binary)
+		app.Dir = dir
+		app.Stdout = os.Stdout
+		app.Stderr = os.Stderr
+		if err := app.Start(); err != nil {
+			logger.RuntimeError("failed to start app: " + err.Error())
+			app = nil
+			return
+		}
+		logger.Success("app restarted")
+	}
+
+	restart()
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Ext(event.Name) != ".go" {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+			// debounce bursts of events into a single rebuild
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(debounce, restart)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.RuntimeError("watch error: " + err.Error())
+		}
+	}
+}
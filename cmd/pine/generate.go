@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// the subset of an OpenAPI 3 document the generator understands
+// only JSON specs are supported
+type openAPISpec struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type parameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type schema struct {
+	Type       string            `json:"type"`
+	Ref        string            `json:"$ref"`
+	Properties map[string]schema `json:"properties"`
+	Items      *schema           `json:"items"`
+}
+
+// runGenerate reads an OpenAPI JSON spec and emits Pine route registrations,
+// typed request structs for the component schemas and stub handlers so that
+// spec-first teams can start from a compiling skeleton
+func runGenerate(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: pine generate <spec.json> [out.go]")
+	}
+	out := "routes_gen.go"
+	if len(args) > 1 {
+		out = args[1]
+	}
+
+	raw, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by pine generate from " + args[0] + ". Edit the handler stubs as needed.\n\n")
+	b.WriteString("package main\n\nimport \"github.com/BryanMwangi/pine\"\n\n")
+
+	writeSchemas(&b, spec)
+	writeRoutes(&b, spec)
+
+	if err := os.WriteFile(out, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	logger.Success("generated " + out)
+	return nil
+}
+
+// writeSchemas emits a typed struct for every component schema
+func writeSchemas(b *strings.Builder, spec openAPISpec) {
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := spec.Components.Schemas[name]
+		b.WriteString("type " + exported(name) + " struct {\n")
+		fields := make([]string, 0, len(s.Properties))
+		for field := range s.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			fmt.Fprintf(b, "\t%s %s `json:\"%s\"`\n", exported(field), goType(s.Properties[field]), field)
+		}
+		b.WriteString("}\n\n")
+	}
+}
+
+// writeRoutes emits the route registration function and one stub handler
+// per operation
+func writeRoutes(b *strings.Builder, spec openAPISpec) {
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	type stub struct{ name, comment string }
+	var stubs []stub
+
+	b.WriteString("// RegisterGeneratedRoutes wires every route found in the spec\n")
+	b.WriteString("func RegisterGeneratedRoutes(app *pine.Server) {\n")
+	for _, p := range paths {
+		methods := make([]string, 0, len(spec.Paths[p]))
+		for m := range spec.Paths[p] {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, m := range methods {
+			op := spec.Paths[p][m]
+			name := op.OperationID
+			if name == "" {
+				name = strings.ToLower(m) + exported(pathIdent(p))
+			}
+			name = exported(name)
+			fmt.Fprintf(b, "\tapp.AddRoute(%q, %q, handle%s)\n", strings.ToUpper(m), pinePath(p), name)
+			stubs = append(stubs, stub{name: name, comment: op.Summary})
+		}
+	}
+	b.WriteString("}\n\n")
+
+	for _, s := range stubs {
+		if s.comment != "" {
+			b.WriteString("// " + s.comment + "\n")
+		}
+		fmt.Fprintf(b, "func handle%s(c *pine.Ctx) error {\n\t// TODO: implement\n\treturn c.SendStatus(501)\n}\n\n", s.name)
+	}
+}
+
+// pinePath converts an OpenAPI path template to Pine's param syntax
+// Eg: /users/{id} becomes /users/:id
+func pinePath(p string) string {
+	p = strings.ReplaceAll(p, "{", ":")
+	return strings.ReplaceAll(p, "}", "")
+}
+
+// pathIdent turns a path into an identifier fragment
+// Eg: /users/{id} becomes UsersId
+func pathIdent(p string) string {
+	parts := strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_'
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(exported(part))
+	}
+	return b.String()
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// goType maps an OpenAPI schema to a Go type
+func goType(s schema) string {
+	if s.Ref != "" {
+		parts := strings.Split(s.Ref, "/")
+		return exported(parts[len(parts)-1])
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		if s.Items != nil {
+			return "[]" + goType(*s.Items)
+		}
+		return "[]interface{}"
+	default:
+		return "interface{}"
+	}
+}
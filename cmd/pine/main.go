@@ -0,0 +1,46 @@
+// Pine's command line tool
+// Run `go install github.com/BryanMwangi/pine/cmd/pine@latest` to install it
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "dev":
+		if err := runDev(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "pine dev:", err)
+			os.Exit(1)
+		}
+	case "new":
+		if err := runNew(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "pine new:", err)
+			os.Exit(1)
+		}
+	case "generate":
+		if err := runGenerate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "pine generate:", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Println(`Pine command line tool
+
+Usage:
+  pine dev [dir]   watch source files, rebuild and restart the app
+  pine new <app>   generate a starter project layout
+  pine generate <spec.json> [out.go]
+                   generate routes and handler stubs from an OpenAPI spec`)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/BryanMwangi/pine/logger"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// the files generated by `pine new`, mapping template name to target path
+// inside the new project
+var scaffold = map[string]string{
+	"templates/main.go.tmpl":       "main.go",
+	"templates/routes.go.tmpl":     "routes/routes.go",
+	"templates/middleware.go.tmpl": "middleware/middleware.go",
+	"templates/gomod.tmpl":         "go.mod",
+	"templates/Dockerfile.tmpl":    "Dockerfile",
+	"templates/env.tmpl":           ".env",
+}
+
+// runNew generates a starter project layout in a new directory named after
+// the app. The generated app compiles as is once `go mod tidy` has run
+func runNew(args []string) error {
+	if len(args) < 1 || args[0] == "" {
+		return errors.New("usage: pine new <app>")
+	}
+	name := args[0]
+
+	if _, err := os.Stat(name); err == nil {
+		return errors.New("directory " + name + " already exists")
+	}
+
+	data := struct {
+		Name string
+	}{Name: name}
+
+	for src, dst := range scaffold {
+		tmpl, err := template.ParseFS(templates, src)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(name, dst)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(f, data); err != nil {
+			f.Close()
+			return err
+		}
+		f.Close()
+		logger.RuntimeInfo("created " + target)
+	}
+
+	logger.Success("project " + name + " created")
+	logger.RuntimeInfo("next steps:")
+	logger.RuntimeInfo("  cd " + name)
+	logger.RuntimeInfo("  go mod tidy")
+	logger.RuntimeInfo("  pine dev")
+	return nil
+}
@@ -0,0 +1,90 @@
+// Pluggable serialization codecs
+// JSON is built in but not everyone speaks it, CBOR and MessagePack are
+// common on constrained devices. RegisterCodec teaches Pine a new format
+// once and binding, response helpers and the client all pick it up by
+// content type, no forking of response code required
+
+package pine
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrNoCodec is returned when a content type has no registered codec
+var ErrNoCodec = errors.New("pine: no codec registered for content type")
+
+// Codec is one serialization format, a pair of marshal and unmarshal
+// functions keyed by content type
+type Codec struct {
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// RegisterCodec makes a serialization format available by content type,
+// registered codecs are used by BindBody, Ctx.Encode and Request.Body:
+//
+//	pine.RegisterCodec("application/cbor", cbor.Marshal, cbor.Unmarshal)
+//
+// Registering a content type twice replaces the earlier codec
+func RegisterCodec(contentType string, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[normalizeContentType(contentType)] = Codec{
+		Marshal:   marshal,
+		Unmarshal: unmarshal,
+	}
+}
+
+// lookupCodec returns the codec registered for contentType, parameters
+// such as charset are ignored
+func lookupCodec(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[normalizeContentType(contentType)]
+	return codec, ok
+}
+
+// Encode writes data in the format registered for contentType, the
+// response side counterpart of BindBody:
+//
+//	c.Encode(user, "application/cbor")
+//
+// ErrNoCodec is returned when the content type has no registered codec,
+// use JSON for the built in format
+// Default status code is 200
+func (c *Ctx) Encode(data interface{}, contentType string, status ...int) error {
+	if c.IsAborted() {
+		return ErrClientAborted
+	}
+	codec, ok := lookupCodec(contentType)
+	if !ok {
+		return ErrNoCodec
+	}
+	raw, err := codec.Marshal(data)
+	if err != nil {
+		return err
+	}
+	c.Response.Header().Set("Content-Type", contentType)
+	if len(status) > 0 {
+		c.Response.WriteHeader(status[0])
+	}
+	c.Response.Write(raw)
+	return nil
+}
+
+// normalizeContentType lowercases a content type and strips its
+// parameters so "Application/CBOR; charset=utf-8" matches
+// "application/cbor"
+func normalizeContentType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
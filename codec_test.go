@@ -0,0 +1,75 @@
+package pine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// the test codec is just JSON under a different content type, enough to
+// prove dispatch happens by content type and not by format
+func registerTestCodec() {
+	RegisterCodec("application/x-test", json.Marshal, json.Unmarshal)
+}
+
+func TestBindBody_RegisteredCodec(t *testing.T) {
+	registerTestCodec()
+
+	body := `{"name": "John"}`
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "Application/X-Test; charset=utf-8")
+	ctx := &Ctx{Request: req}
+
+	var data struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.BindBody(&data); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if data.Name != "John" {
+		t.Fatalf("expected name 'John', got '%s'", data.Name)
+	}
+}
+
+func TestBindBody_FallsBackToJSON(t *testing.T) {
+	body := `{"name": "John"}`
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	ctx := &Ctx{Request: req}
+
+	var data struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.BindBody(&data); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if data.Name != "John" {
+		t.Fatalf("expected name 'John', got '%s'", data.Name)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	registerTestCodec()
+
+	ctx := Mock_Ctx()
+	if err := ctx.Encode(map[string]string{"name": "John"}, "application/x-test"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	recorder := ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+	if got := recorder.Header().Get("Content-Type"); got != "application/x-test" {
+		t.Fatalf("expected content type 'application/x-test', got '%s'", got)
+	}
+	if !bytes.Contains(recorder.Body.Bytes(), []byte(`"name":"John"`)) {
+		t.Fatalf("unexpected body: %s", recorder.Body.String())
+	}
+}
+
+func TestEncode_NoCodec(t *testing.T) {
+	ctx := Mock_Ctx()
+	if err := ctx.Encode(map[string]string{}, "application/x-unregistered"); err != ErrNoCodec {
+		t.Fatalf("expected ErrNoCodec, got %v", err)
+	}
+}
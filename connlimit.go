@@ -0,0 +1,116 @@
+package pine
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnLimitConfig caps concurrent connections at the listener, before any
+// request parsing happens. This resists simple floods far cheaper than a
+// rate limiter can since excess connections never reach the HTTP server
+type ConnLimitConfig struct {
+	// MaxConns is the total number of concurrent connections accepted
+	//
+	// Default: 0 which means unlimited
+	MaxConns int
+
+	// MaxConnsPerIP is the number of concurrent connections accepted from
+	// a single client IP
+	//
+	// Default: 0 which means unlimited
+	MaxConnsPerIP int
+}
+
+// enabled reports whether any limit is configured
+func (cfg ConnLimitConfig) enabled() bool {
+	return cfg.MaxConns > 0 || cfg.MaxConnsPerIP > 0
+}
+
+// limitedListener wraps a listener and rejects connections over the limits
+type limitedListener struct {
+	net.Listener
+	cfg ConnLimitConfig
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+func newLimitedListener(inner net.Listener, cfg ConnLimitConfig) *limitedListener {
+	return &limitedListener{
+		Listener: inner,
+		cfg:      cfg,
+		perIP:    make(map[string]int),
+	}
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := remoteIP(conn)
+		l.mu.Lock()
+		over := (l.cfg.MaxConns > 0 && l.total >= l.cfg.MaxConns) ||
+			(l.cfg.MaxConnsPerIP > 0 && l.perIP[ip] >= l.cfg.MaxConnsPerIP)
+		if over {
+			l.mu.Unlock()
+			// reject in the background so a slow client cannot stall Accept
+			go reject(conn)
+			continue
+		}
+		l.total++
+		l.perIP[ip]++
+		l.mu.Unlock()
+
+		return &countedConn{Conn: conn, listener: l, ip: ip}, nil
+	}
+}
+
+// release is called once per accepted connection when it closes
+func (l *limitedListener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	l.perIP[ip]--
+	if l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// countedConn decrements the listener's counters exactly once on close
+type countedConn struct {
+	net.Conn
+	listener *limitedListener
+	ip       string
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.listener.release(c.ip)
+	})
+	return c.Conn.Close()
+}
+
+// reject answers an over-limit connection with a canned 503 and closes it.
+// The raw bytes are written directly since the connection never reaches
+// the HTTP server
+func reject(conn net.Conn) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("HTTP/1.1 503 Service Unavailable\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+}
+
+// remoteIP extracts the client IP from a connection, falling back to the
+// whole address when it cannot be split
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
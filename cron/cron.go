@@ -1,13 +1,22 @@
 package cron
 
 import (
+	"fmt"
+	"math/rand"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/BryanMwangi/pine"
 	"github.com/BryanMwangi/pine/logger"
+	"github.com/BryanMwangi/pine/metrics"
 	"github.com/google/uuid"
 )
 
+// failures counts jobs that returned an error, exported through the
+// metrics package
+var failures = metrics.NewCounter("cron_failures")
+
 type Config struct {
 	// When set to true the server will attempt to restart failed jobs
 	RestartOnError bool
@@ -40,6 +49,12 @@ type Cron struct {
 
 	// Ensures that updates to the jobs slice are thread safe
 	mutex sync.Mutex
+
+	// one-shot delayed task bookkeeping, see tasks.go
+	taskMu   sync.Mutex
+	handlers map[string]func() error
+	timers   map[string]*time.Timer
+	store    TaskStore
 }
 
 // This is the structure of a background job
@@ -57,6 +72,16 @@ type Job struct {
 	id   uuid.UUID
 	Fn   func() error
 	Time time.Duration
+
+	// Jitter adds a random delay of up to this duration before each run so
+	// many instances started together do not hammer downstreams at the
+	// same moment
+	Jitter time.Duration
+
+	// AlignToInterval waits for the next wall clock boundary of the job's
+	// interval instead of counting from the start time, Eg: a one minute
+	// job then runs at :00 seconds on every instance
+	AlignToInterval bool
 }
 
 const (
@@ -118,6 +143,8 @@ func (c *Cron) handleJobError(job Job) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	failures.Inc()
+
 	// Check if the config has a restart policy
 	// If no restart policy is set, we delete the job immediately
 	if !c.config.RestartOnError {
@@ -140,15 +167,46 @@ func (c *Cron) handleJobError(job Job) {
 	logger.RuntimeInfo("Job will be retried in " + job.Time.String())
 }
 
+// runJob executes one iteration of the job, turning a panic into an error
+// so one misbehaving job cannot kill the whole process. The stack trace is
+// logged and the restart policy applies to panics the same way it does to
+// returned errors
+func runJob(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.RuntimeError("Panic in cron job " + getFunctionName(job.Fn))
+			logger.RuntimeError(string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Fn()
+}
+
 func (c *Cron) startJob(job Job) {
+	interval := job.Time
+	if interval <= 0 {
+		interval = c.config.BackgroundTimeout
+	}
+
+	// aligned jobs wait for the first boundary before running at all
+	if job.AlignToInterval {
+		time.Sleep(untilNextBoundary(interval))
+	}
+
 	for {
+		// spread simultaneous instances out before each run
+		if job.Jitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(job.Jitter))))
+		}
+
 		// Execute the task function
-		err := job.Fn()
+		err := runJob(job)
 		if err != nil {
 			// Log the error
 			logger.RuntimeError("Error in cron job")
 			logger.RuntimeError(getFunctionName(job.Fn))
 			logger.RuntimeError(err.Error())
+			pine.NotifyError(err, "cron")
 
 			// Remove the task if it fails
 			c.handleJobError(job)
@@ -158,14 +216,21 @@ func (c *Cron) startJob(job Job) {
 			}
 		}
 		// Respect the delay specified by the task
-		if job.Time > 0 {
-			time.Sleep(job.Time)
+		if job.AlignToInterval {
+			time.Sleep(untilNextBoundary(interval))
 		} else {
-			time.Sleep(c.config.BackgroundTimeout)
+			time.Sleep(interval)
 		}
 	}
 }
 
+// untilNextBoundary is how long to wait until the wall clock reaches the
+// next multiple of interval
+func untilNextBoundary(interval time.Duration) time.Duration {
+	now := time.Now()
+	return now.Truncate(interval).Add(interval).Sub(now)
+}
+
 func (c *Cron) jobExists(id uuid.UUID) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
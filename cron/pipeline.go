@@ -0,0 +1,153 @@
+// Cron pipelines
+// A pipeline is a set of named steps that run on one interval where a step
+// can declare the steps it depends on. Within a cycle a step only runs once
+// everything it depends on has succeeded, and it is skipped when any of them
+// failed — a small DAG for multi step maintenance work such as
+// export -> compress -> upload -> prune
+//
+//	pipeline := c.AddPipeline(time.Hour,
+//		cron.Step{Name: "export", Fn: export},
+//		cron.Step{Name: "upload", Fn: upload, After: []string{"export"}},
+//	)
+//
+// The outcome of the most recent cycle is available through Status
+
+package cron
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Step is one named unit of work in a pipeline
+type Step struct {
+	// Name identifies the step in dependencies and status output
+	Name string
+
+	// Fn does the work, a non nil error marks the step failed for the
+	// cycle and skips everything that depends on it
+	Fn func() error
+
+	// After lists the names of steps that must succeed in the current
+	// cycle before this one runs
+	After []string
+}
+
+// StepStatus is the outcome of a step within one cycle
+type StepStatus string
+
+const (
+	// StepPending means the step has not run yet this cycle
+	StepPending StepStatus = "pending"
+	// StepSucceeded means the step completed without an error
+	StepSucceeded StepStatus = "succeeded"
+	// StepFailed means the step returned an error or panicked
+	StepFailed StepStatus = "failed"
+	// StepSkipped means a dependency failed or could never be satisfied
+	StepSkipped StepStatus = "skipped"
+)
+
+// Pipeline tracks the steps and the outcome of the most recent cycle,
+// create one with AddPipeline
+type Pipeline struct {
+	steps []Step
+
+	mu   sync.Mutex
+	last map[string]StepStatus
+}
+
+// AddPipeline schedules the steps as one job on the given interval, steps
+// run in dependency order within each cycle. Jitter and alignment are not
+// applied, wrap the pipeline in your own Job for that
+func (c *Cron) AddPipeline(interval time.Duration, steps ...Step) *Pipeline {
+	p := &Pipeline{steps: steps}
+	c.AddJobs(Job{
+		Time: interval,
+		// a failing step must not unschedule the whole pipeline, failures
+		// are recorded in the cycle status instead
+		Fn: func() error {
+			p.runCycle()
+			return nil
+		},
+	})
+	return p
+}
+
+// Status returns the outcome of every step in the most recent cycle, empty
+// before the first cycle has run
+func (p *Pipeline) Status() map[string]StepStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]StepStatus, len(p.last))
+	for name, status := range p.last {
+		out[name] = status
+	}
+	return out
+}
+
+// runCycle executes one pass over the DAG
+func (p *Pipeline) runCycle() {
+	status := make(map[string]StepStatus, len(p.steps))
+	for _, step := range p.steps {
+		status[step.Name] = StepPending
+	}
+
+	// keep sweeping until no step can make progress, each sweep runs the
+	// steps whose dependencies are settled
+	progress := true
+	for progress {
+		progress = false
+		for _, step := range p.steps {
+			if status[step.Name] != StepPending {
+				continue
+			}
+			ready := true
+			blocked := false
+			for _, dep := range step.After {
+				switch status[dep] {
+				case StepSucceeded:
+				case StepFailed, StepSkipped:
+					blocked = true
+				default:
+					ready = false
+				}
+			}
+			if blocked {
+				status[step.Name] = StepSkipped
+				logger.RuntimeInfo("Pipeline step " + step.Name + " skipped, a dependency did not succeed")
+				progress = true
+				continue
+			}
+			if !ready {
+				continue
+			}
+			if err := runStep(step); err != nil {
+				status[step.Name] = StepFailed
+				logger.RuntimeError("Pipeline step " + step.Name + " failed: " + err.Error())
+			} else {
+				status[step.Name] = StepSucceeded
+			}
+			progress = true
+		}
+	}
+
+	// anything still pending depends on an unknown step or sits in a
+	// dependency cycle, it can never run
+	for name, s := range status {
+		if s == StepPending {
+			status[name] = StepSkipped
+			logger.RuntimeError("Pipeline step " + name + " has an unsatisfiable dependency")
+		}
+	}
+
+	p.mu.Lock()
+	p.last = status
+	p.mu.Unlock()
+}
+
+// runStep executes one step with the same panic isolation jobs get
+func runStep(step Step) error {
+	return runJob(Job{Fn: step.Fn})
+}
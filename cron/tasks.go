@@ -0,0 +1,167 @@
+// One-shot delayed tasks
+// After runs a function once after a delay, for fire and forget work such
+// as cancelling an unpaid order after 15 minutes. Tasks scheduled through
+// ScheduleAt are named and can be persisted through a TaskStore so pending
+// work survives restarts:
+//
+//	c.RegisterTask("cancel-order", func() error { ... })
+//	c.UseStore(myStore)
+//	c.ScheduleAt("cancel-order", time.Now().Add(15*time.Minute))
+//
+// After a restart UseStore reloads every pending task and re-arms its
+// timer, overdue tasks run immediately
+
+package cron
+
+import (
+	"errors"
+	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/google/uuid"
+)
+
+// PendingTask is the persistable description of a scheduled task, the
+// function itself is looked up by name through RegisterTask
+type PendingTask struct {
+	ID    string    `json:"id"`
+	Name  string    `json:"name"`
+	RunAt time.Time `json:"run_at"`
+}
+
+// TaskStore persists pending tasks so they survive restarts, back it with
+// whatever your app already has, a database table or a file
+type TaskStore interface {
+	SaveTask(task PendingTask) error
+	DeleteTask(id string) error
+	LoadTasks() ([]PendingTask, error)
+}
+
+// ErrUnknownTask is returned when a task name has no registered handler
+var ErrUnknownTask = errors.New("cron: no handler registered for task")
+
+// After runs fn once after delay, with the same panic isolation cron jobs
+// get. Use ScheduleAt instead when the task must survive a restart
+func (c *Cron) After(delay time.Duration, fn func() error) {
+	time.AfterFunc(delay, func() {
+		if err := runJob(Job{Fn: fn}); err != nil {
+			logger.RuntimeError("Error in one-shot task: " + err.Error())
+		}
+	})
+}
+
+// RegisterTask names a handler so persisted tasks can find their function
+// again after a restart, register every handler before calling UseStore
+func (c *Cron) RegisterTask(name string, fn func() error) {
+	c.taskMu.Lock()
+	defer c.taskMu.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[string]func() error)
+	}
+	c.handlers[name] = fn
+}
+
+// UseStore attaches a persistence store and re-arms every pending task in
+// it, tasks whose time already passed run immediately
+func (c *Cron) UseStore(store TaskStore) error {
+	c.taskMu.Lock()
+	c.store = store
+	c.taskMu.Unlock()
+
+	pending, err := store.LoadTasks()
+	if err != nil {
+		return err
+	}
+	for _, task := range pending {
+		c.arm(task)
+	}
+	return nil
+}
+
+// ScheduleAt runs a registered task once at the given time and returns the
+// task id. The task is saved to the store when one is attached
+func (c *Cron) ScheduleAt(name string, at time.Time) (string, error) {
+	c.taskMu.Lock()
+	_, ok := c.handlers[name]
+	store := c.store
+	c.taskMu.Unlock()
+	if !ok {
+		return "", ErrUnknownTask
+	}
+
+	task := PendingTask{
+		ID:    uuid.New().String(),
+		Name:  name,
+		RunAt: at,
+	}
+	if store != nil {
+		if err := store.SaveTask(task); err != nil {
+			return "", err
+		}
+	}
+	c.arm(task)
+	return task.ID, nil
+}
+
+// CancelTask stops a pending task and removes it from the store, false when
+// the task already ran or never existed
+func (c *Cron) CancelTask(id string) bool {
+	c.taskMu.Lock()
+	timer, ok := c.timers[id]
+	delete(c.timers, id)
+	store := c.store
+	c.taskMu.Unlock()
+
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	if store != nil {
+		if err := store.DeleteTask(id); err != nil {
+			logger.RuntimeError("Failed to delete task from store: " + err.Error())
+		}
+	}
+	return true
+}
+
+// arm starts the timer for one pending task
+func (c *Cron) arm(task PendingTask) {
+	delay := time.Until(task.RunAt)
+	if delay < 0 {
+		delay = 0
+	}
+
+	c.taskMu.Lock()
+	if c.timers == nil {
+		c.timers = make(map[string]*time.Timer)
+	}
+	c.timers[task.ID] = time.AfterFunc(delay, func() {
+		c.runTask(task)
+	})
+	c.taskMu.Unlock()
+}
+
+// runTask executes a due task and cleans it up
+func (c *Cron) runTask(task PendingTask) {
+	c.taskMu.Lock()
+	fn, ok := c.handlers[task.Name]
+	delete(c.timers, task.ID)
+	store := c.store
+	c.taskMu.Unlock()
+
+	if !ok {
+		// the handler disappeared across a restart, keep the task in the
+		// store so a later deploy with the handler can pick it up
+		logger.RuntimeError("No handler registered for task " + task.Name)
+		return
+	}
+
+	if err := runJob(Job{Fn: fn}); err != nil {
+		logger.RuntimeError("Error in scheduled task " + task.Name + ": " + err.Error())
+	}
+	if store != nil {
+		if err := store.DeleteTask(task.ID); err != nil {
+			logger.RuntimeError("Failed to delete task from store: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package pine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+)
+
+// CSV writes rows as a text/csv response, useful for export endpoints in
+// admin backends
+//
+// rows can be a [][]string written as is, or a slice of structs in which
+// case a header row is derived from the `csv` struct tags, falling back to
+// the field names. Pass headers to override the derived header row,
+// Eg: c.CSV(users, "ID", "Name", "Email")
+func (c *Ctx) CSV(rows interface{}, headers ...string) error {
+	c.Set("Content-Type", "text/csv")
+	c.Response.flushStatus()
+	w := csv.NewWriter(c.Response)
+	defer w.Flush()
+
+	if records, ok := rows.([][]string); ok {
+		if len(headers) > 0 {
+			if err := w.Write(headers); err != nil {
+				return err
+			}
+		}
+		return w.WriteAll(records)
+	}
+
+	v := reflect.ValueOf(rows)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return ErrType
+	}
+
+	elemType := v.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return ErrType
+	}
+
+	header := headers
+	if len(header) == 0 {
+		for i := 0; i < elemType.NumField(); i++ {
+			header = append(header, csvColumn(elemType.Field(i)))
+		}
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		record := make([]string, row.NumField())
+		for j := 0; j < row.NumField(); j++ {
+			record[j] = fmt.Sprint(row.Field(j).Interface())
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindCSV parses a CSV request body into a slice of structs. The first row
+// must be a header row and columns are matched to fields through the `csv`
+// struct tag, falling back to the field name
+//
+//	type Row struct {
+//		ID   int    `csv:"id"`
+//		Name string `csv:"name"`
+//	}
+//	var rows []Row
+//	if err := c.BindCSV(&rows); err != nil { ... }
+func (c *Ctx) BindCSV(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr {
+		return ErrPtr
+	}
+	slice := ptr.Elem()
+	if slice.Kind() != reflect.Slice {
+		return ErrType
+	}
+	elemType := slice.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return ErrType
+	}
+
+	records, err := csv.NewReader(c.Request.Body).ReadAll()
+	if err != nil {
+		return ErrParse
+	}
+	if len(records) < 1 {
+		return ErrParse
+	}
+
+	// map column index to struct field index through the header row
+	columns := make(map[int]int)
+	for col, name := range records[0] {
+		for i := 0; i < elemType.NumField(); i++ {
+			if csvColumn(elemType.Field(i)) == name {
+				columns[col] = i
+				break
+			}
+		}
+	}
+
+	for _, record := range records[1:] {
+		row := reflect.New(elemType).Elem()
+		for col, fieldIndex := range columns {
+			if col >= len(record) {
+				continue
+			}
+			if err := bind(record[col], row.Field(fieldIndex).Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		slice.Set(reflect.Append(slice, row))
+	}
+	return nil
+}
+
+// csvColumn returns the column name of a struct field, preferring the csv tag
+func csvColumn(field reflect.StructField) string {
+	if tag := field.Tag.Get("csv"); tag != "" {
+		return tag
+	}
+	return field.Name
+}
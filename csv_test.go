@@ -0,0 +1,65 @@
+package pine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type csvRow struct {
+	ID   int    `csv:"id"`
+	Name string `csv:"name"`
+}
+
+func TestCSV_StructRows(t *testing.T) {
+	ctx := Mock_Ctx()
+	rows := []csvRow{
+		{ID: 1, Name: "pine"},
+		{ID: 2, Name: "oak"},
+	}
+
+	if err := ctx.CSV(rows); err != nil {
+		t.Fatalf("CSV failed: %v", err)
+	}
+
+	body := string(ctx.Response.Body())
+	if !strings.HasPrefix(body, "id,name\n") {
+		t.Errorf("expected header row, got %s", body)
+	}
+	if !strings.Contains(body, "1,pine") || !strings.Contains(body, "2,oak") {
+		t.Errorf("expected both rows in output, got %s", body)
+	}
+}
+
+func TestBindCSV(t *testing.T) {
+	payload := "id,name\n1,pine\n2,oak\n"
+	req := httptest.NewRequest(http.MethodPost, "/import", bytes.NewBufferString(payload))
+	ctx := &Ctx{Request: req}
+
+	var rows []csvRow
+	if err := ctx.BindCSV(&rows); err != nil {
+		t.Fatalf("BindCSV failed: %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].ID != 1 || rows[0].Name != "pine" {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	if rows[1].ID != 2 || rows[1].Name != "oak" {
+		t.Errorf("unexpected second row: %+v", rows[1])
+	}
+}
+
+func TestBindCSV_NotAPointer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/import", bytes.NewBufferString("id\n1\n"))
+	ctx := &Ctx{Request: req}
+
+	var rows []csvRow
+	if err := ctx.BindCSV(rows); err != ErrPtr {
+		t.Errorf("expected ErrPtr, got %v", err)
+	}
+}
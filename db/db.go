@@ -0,0 +1,106 @@
+// Pine's database integration helpers
+// Most services open one or more connection pools, ping them from a health
+// endpoint and close them on shutdown. This package removes that
+// boilerplate: register each pool once and the rest is wired for you,
+//
+//	pool, _ := sql.Open("postgres", dsn)
+//	db.Register(app, "primary", pool)
+//	db.RegisterHealth(app, "/healthz")
+//
+// Closing happens automatically inside ServeShutDown through the server's
+// shutdown hooks
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Pool is the subset of *sql.DB the helpers rely on, satisfied by any
+// database/sql pool and easy to fake in tests
+type Pool interface {
+	PingContext(ctx context.Context) error
+	Stats() sql.DBStats
+	Close() error
+}
+
+// pingTimeout bounds each health check ping
+const pingTimeout = 2 * time.Second
+
+var (
+	mu    sync.RWMutex
+	pools = make(map[string]Pool)
+)
+
+// Register adds a named pool to the registry and hooks its Close into the
+// server's shutdown
+func Register(server *pine.Server, name string, pool Pool) {
+	mu.Lock()
+	pools[name] = pool
+	mu.Unlock()
+
+	server.OnShutdown(func() {
+		if err := pool.Close(); err != nil {
+			logger.RuntimeError("failed to close pool " + name + ": " + err.Error())
+		}
+	})
+}
+
+// Pools returns a snapshot of the registered pools, mainly useful for
+// exposing stats in metrics
+func Pools() map[string]Pool {
+	mu.RLock()
+	defer mu.RUnlock()
+	snapshot := make(map[string]Pool, len(pools))
+	for name, pool := range pools {
+		snapshot[name] = pool
+	}
+	return snapshot
+}
+
+// HealthHandler pings every registered pool and reports per pool status.
+// The response is a 200 when all pools answer and a 503 otherwise
+func HealthHandler() pine.Handler {
+	return func(c *pine.Ctx) error {
+		status := http.StatusOK
+		report := make(map[string]string)
+
+		for name, pool := range Pools() {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), pingTimeout)
+			err := pool.PingContext(ctx)
+			cancel()
+			if err != nil {
+				report[name] = err.Error()
+				status = http.StatusServiceUnavailable
+			} else {
+				report[name] = "ok"
+			}
+		}
+		return c.JSON(report, status)
+	}
+}
+
+// StatsHandler exposes each pool's sql.DBStats as JSON, handy for
+// debugging connection leaks or sizing the pools
+func StatsHandler() pine.Handler {
+	return func(c *pine.Ctx) error {
+		report := make(map[string]sql.DBStats)
+		for name, pool := range Pools() {
+			report[name] = pool.Stats()
+		}
+		return c.JSON(report)
+	}
+}
+
+// RegisterHealth wires HealthHandler at the given path,
+// Eg: db.RegisterHealth(app, "/healthz")
+func RegisterHealth(server *pine.Server, path string) {
+	server.Get(path, HealthHandler())
+}
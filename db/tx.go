@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Tx is a transaction in flight. Anything with Commit and Rollback fits,
+// so ORMs and raw database/sql can both be used
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// TxBeginner starts transactions for the Transaction middleware. Wrap a
+// *sql.DB with SQL or implement this for your own store
+type TxBeginner interface {
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// TxKey is the default locals key the transaction is stored under
+const TxKey = "tx"
+
+// sqlBeginner adapts *sql.DB to TxBeginner
+type sqlBeginner struct {
+	pool *sql.DB
+}
+
+// SQL wraps a database/sql pool for use with Transaction
+func SQL(pool *sql.DB) TxBeginner {
+	return sqlBeginner{pool: pool}
+}
+
+func (b sqlBeginner) Begin(ctx context.Context) (Tx, error) {
+	return b.pool.BeginTx(ctx, nil)
+}
+
+// Transaction begins a transaction for every request and stores it in the
+// locals under TxKey, or the key you pass. The transaction is committed
+// when the handler succeeds and rolled back when it returns an error or
+// panics, so handlers never touch Commit or Rollback themselves,
+//
+//	app.Use(db.Transaction(db.SQL(pool)))
+//
+//	func createUser(c *pine.Ctx) error {
+//		tx := c.Locals(db.TxKey).(*sql.Tx)
+//		...
+//	}
+func Transaction(beginner TxBeginner, localsKey ...string) pine.Middleware {
+	key := TxKey
+	if len(localsKey) > 0 && localsKey[0] != "" {
+		key = localsKey[0]
+	}
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) (err error) {
+			tx, err := beginner.Begin(c.Request.Context())
+			if err != nil {
+				return err
+			}
+			c.Locals(key, tx)
+
+			// a panicking handler must not leave the transaction open,
+			// roll it back and let the server's recovery take over
+			defer func() {
+				if r := recover(); r != nil {
+					tx.Rollback()
+					panic(r)
+				}
+			}()
+
+			if err = next(c); err != nil {
+				tx.Rollback()
+				return err
+			}
+			return tx.Commit()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package pine
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httputil"
+	"runtime/debug"
+)
+
+// handlerError wraps an error returned or recovered from a handler together
+// with the stack trace captured at the point of a panic
+type handlerError struct {
+	err   error
+	stack []byte
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// runHandler executes a handler and converts panics into errors so that a
+// misbehaving handler cannot take down the whole server
+func (server *Server) runHandler(handler Handler, c *Ctx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &handlerError{
+				err:   fmt.Errorf("panic: %v", r),
+				stack: debug.Stack(),
+			}
+		}
+	}()
+	return handler(c)
+}
+
+// handleError is the central error handler called when a handler returns an
+// error or panics
+//
+// In debug mode a detailed HTML error page with the stack trace and a dump
+// of the request is rendered to make development easier. In production the
+// error is logged and a sanitized message is returned instead so that no
+// internals leak to clients
+func (server *Server) handleError(c *Ctx, err error) {
+	// validation failures are client mistakes, render every field error as
+	// a map so forms can display them all at once
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		c.JSON(map[string]interface{}{"errors": ve}, http.StatusBadRequest)
+		return
+	}
+
+	NotifyError(err, "handler")
+
+	server.errorLog.Printf("%s %s: %v", c.Method, c.BaseURI, err)
+
+	if !server.config.Debug {
+		http.Error(c.Response.ResponseWriter, message(server.config.Messages.InternalServerError, http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+		return
+	}
+
+	var stack []byte
+	if he, ok := err.(*handlerError); ok {
+		stack = he.stack
+	}
+	if stack == nil {
+		stack = debug.Stack()
+	}
+
+	dump, dumpErr := httputil.DumpRequest(c.Request, true)
+	if dumpErr != nil {
+		dump = []byte(dumpErr.Error())
+	}
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Response.WriteHeader(http.StatusInternalServerError)
+	page := fmt.Sprintf(debugErrorPage,
+		html.EscapeString(err.Error()),
+		html.EscapeString(c.Method),
+		html.EscapeString(c.BaseURI),
+		html.EscapeString(string(stack)),
+		html.EscapeString(string(dump)),
+	)
+	c.Response.Write([]byte(page))
+}
+
+// the HTML template of the debug error page
+// kept as a plain format string to avoid pulling in html/template
+const debugErrorPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>Pine - Internal Server Error</title>
+<style>
+body { font-family: monospace; margin: 2em; background: #fdf6f6; }
+h1 { color: #b30000; }
+pre { background: #fff; border: 1px solid #ddd; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Internal Server Error</h1>
+<p><strong>%s</strong></p>
+<p>%s %s</p>
+<h2>Stack trace</h2>
+<pre>%s</pre>
+<h2>Request</h2>
+<pre>%s</pre>
+<p>You are seeing this page because Config.Debug is enabled</p>
+</body>
+</html>
+`
@@ -0,0 +1,362 @@
+// Pine's implementation of request deduplication
+// This is a middleware that coalesces identical concurrent GET requests so
+// that a thundering herd of clients results in a single backend execution.
+// The first request executes the handler and every request that arrives while
+// it is in flight waits and is served a copy of the same response
+
+// Requests are considered identical when they share the same method, path,
+// query string and the values of the configured vary headers
+
+// When a TTL is configured the middleware also caches completed responses.
+// Expired entries can keep being served for a grace period while a single
+// request revalidates them (stale-while-revalidate) and when that refresh
+// fails the stale copy keeps flowing for a further window (stale-if-error),
+// so clients rarely see the latency or the failure of the origin
+
+package dedupe
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+)
+
+type Config struct {
+	// Defines the headers whose values become part of the coalescing key.
+	// Use this when responses differ per header, Eg: "Accept-Encoding" or
+	// "Authorization"
+	//
+	// Default: []string{}
+	VaryHeaders []string
+
+	// VaryByAccept includes the request's Accept header in the key so each
+	// negotiated representation is cached separately
+	//
+	// Default: false
+	VaryByAccept bool
+
+	// VaryByEncoding includes the content encodings the request supports in
+	// the key, normalized so header ordering does not fragment the cache
+	//
+	// Default: false
+	VaryByEncoding bool
+
+	// VaryByAuthPresence includes whether the request carries an
+	// Authorization header, so anonymous and authenticated responses never
+	// mix. The credential value itself is not part of the key, add
+	// "Authorization" to VaryHeaders for per user caching
+	//
+	// Default: false
+	VaryByAuthPresence bool
+
+	// KeyGen can be used to fully replace the built in key generation
+	//
+	// Default: method + request URI + vary header values
+	KeyGen func(c *pine.Ctx) string
+
+	// TTL caches successful responses for this long after completion so
+	// repeated requests are served from memory. Zero keeps coalescing
+	// without any caching
+	//
+	// Default: 0
+	TTL time.Duration
+
+	// StaleWhileRevalidate serves an expired entry for up to this long
+	// past its TTL while a single request refreshes it, everyone else
+	// gets the stale copy immediately
+	//
+	// Default: 0
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError keeps serving an expired entry for up to this long
+	// past its TTL when the refresh fails
+	//
+	// Default: 0
+	StaleIfError time.Duration
+}
+
+// entry is a cached response together with its bookkeeping
+type entry struct {
+	status     int
+	header     http.Header
+	body       []byte
+	storedAt   time.Time
+	refreshing bool
+}
+
+// writeEntry replays a cached response to a client
+func writeEntry(c *pine.Ctx, e *entry) error {
+	for k, v := range e.header {
+		for _, val := range v {
+			c.Response.Header().Add(k, val)
+		}
+	}
+	if e.status != 0 {
+		c.Response.WriteHeader(e.status)
+	}
+	_, err := c.Response.Write(e.body)
+	return err
+}
+
+// errHandlerPanic is handed to waiters when the leader panicked before a
+// response could be recorded, so they fail instead of replaying an empty one
+var errHandlerPanic = errors.New("dedupe: coalesced handler panicked")
+
+// call holds the in flight execution that waiters subscribe to
+// This is for internal use and you should not need to change it
+type call struct {
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		VaryHeaders: []string{},
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.VaryHeaders != nil {
+			cfg.VaryHeaders = userConfig.VaryHeaders
+		}
+		cfg.VaryByAccept = userConfig.VaryByAccept
+		cfg.VaryByEncoding = userConfig.VaryByEncoding
+		cfg.VaryByAuthPresence = userConfig.VaryByAuthPresence
+		if userConfig.KeyGen != nil {
+			cfg.KeyGen = userConfig.KeyGen
+		}
+		if userConfig.TTL > 0 {
+			cfg.TTL = userConfig.TTL
+		}
+		if userConfig.StaleWhileRevalidate > 0 {
+			cfg.StaleWhileRevalidate = userConfig.StaleWhileRevalidate
+		}
+		if userConfig.StaleIfError > 0 {
+			cfg.StaleIfError = userConfig.StaleIfError
+		}
+	}
+
+	var mu sync.Mutex
+	calls := make(map[string]*call)
+	store := make(map[string]*entry)
+	vary := cfg.varyValue()
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			// only GET requests are safe to coalesce
+			if c.Method != pine.MethodGet {
+				return next(c)
+			}
+
+			key := cfg.key(c)
+
+			// serve from the response cache first when one is configured
+			if cfg.TTL > 0 {
+				mu.Lock()
+				if e, ok := store[key]; ok {
+					age := time.Since(e.storedAt)
+					maxStale := cfg.StaleWhileRevalidate
+					if cfg.StaleIfError > maxStale {
+						maxStale = cfg.StaleIfError
+					}
+					switch {
+					case age <= cfg.TTL:
+						mu.Unlock()
+						return writeEntry(c, e)
+					case age <= cfg.TTL+maxStale:
+						if e.refreshing {
+							// a refresh is already under way, keep
+							// serving the stale copy meanwhile
+							mu.Unlock()
+							return writeEntry(c, e)
+						}
+						e.refreshing = true
+						mu.Unlock()
+
+						// the marker is reset under defer so a refresh
+						// that panics does not block every later
+						// revalidation of this entry
+						defer func() {
+							mu.Lock()
+							e.refreshing = false
+							mu.Unlock()
+						}()
+
+						// this request becomes the single refresher
+						if vary != "" {
+							c.Set("Vary", vary)
+						}
+						err := next(c)
+						status := c.Response.StatusCode()
+
+						mu.Lock()
+						if err == nil && status < http.StatusInternalServerError {
+							store[key] = &entry{
+								status:   status,
+								header:   c.Response.Header().Clone(),
+								body:     c.Response.Body(),
+								storedAt: time.Now(),
+							}
+							mu.Unlock()
+							return nil
+						}
+						// the refresh failed, fall back to the stale
+						// copy while the stale-if-error window allows
+						withinSIE := age <= cfg.TTL+cfg.StaleIfError
+						if !withinSIE {
+							delete(store, key)
+						}
+						mu.Unlock()
+						if withinSIE && err != nil && c.Response.BodyLen() == 0 {
+							return writeEntry(c, e)
+						}
+						return err
+					default:
+						// too old for any grace period
+						delete(store, key)
+						mu.Unlock()
+					}
+				} else {
+					mu.Unlock()
+				}
+			}
+
+			mu.Lock()
+			if inflight, ok := calls[key]; ok {
+				mu.Unlock()
+				// another identical request is already executing,
+				// wait for it and fan out its response
+				<-inflight.done
+				if inflight.err != nil {
+					return inflight.err
+				}
+				for k, v := range inflight.header {
+					for _, val := range v {
+						c.Response.Header().Add(k, val)
+					}
+				}
+				if inflight.status != 0 {
+					c.Response.WriteHeader(inflight.status)
+				}
+				_, err := c.Response.Write(inflight.body)
+				return err
+			}
+
+			leader := &call{done: make(chan struct{})}
+			calls[key] = leader
+			mu.Unlock()
+
+			// cleanup is deferred so a panicking handler still removes
+			// the key and releases the waiters, otherwise the route
+			// would coalesce onto a leader that never finishes
+			recorded := false
+			defer func() {
+				if !recorded {
+					leader.err = errHandlerPanic
+				}
+				mu.Lock()
+				delete(calls, key)
+				// successful responses populate the cache for later
+				// requests
+				if recorded && cfg.TTL > 0 && leader.err == nil && leader.status < http.StatusInternalServerError {
+					store[key] = &entry{
+						status:   leader.status,
+						header:   leader.header,
+						body:     leader.body,
+						storedAt: time.Now(),
+					}
+				}
+				mu.Unlock()
+				close(leader.done)
+			}()
+
+			// the Vary header advertises what the key depends on so shared
+			// caches downstream split their entries the same way we do
+			if vary != "" {
+				c.Set("Vary", vary)
+			}
+			err := next(c)
+
+			// record the response before releasing the waiters
+			leader.err = err
+			leader.status = c.Response.StatusCode()
+			leader.header = c.Response.Header().Clone()
+			leader.body = c.Response.Body()
+			recorded = true
+
+			return err
+		}
+	}
+}
+
+// key builds the coalescing key for a request
+func (cfg *Config) key(c *pine.Ctx) string {
+	if cfg.KeyGen != nil {
+		return cfg.KeyGen(c)
+	}
+	var b strings.Builder
+	b.WriteString(c.Method)
+	b.WriteString(":")
+	b.WriteString(c.Request.URL.RequestURI())
+	if cfg.VaryByAccept {
+		b.WriteString(":")
+		b.WriteString(strings.ToLower(strings.ReplaceAll(c.Header("Accept"), " ", "")))
+	}
+	if cfg.VaryByEncoding {
+		b.WriteString(":")
+		b.WriteString(normalizeEncodings(c.Header("Accept-Encoding")))
+	}
+	if cfg.VaryByAuthPresence {
+		if c.Header("Authorization") != "" {
+			b.WriteString(":auth")
+		} else {
+			b.WriteString(":anon")
+		}
+	}
+	for _, header := range cfg.VaryHeaders {
+		b.WriteString(":")
+		b.WriteString(c.Header(header))
+	}
+	return b.String()
+}
+
+// varyValue builds the Vary response header matching the configured key
+func (cfg *Config) varyValue() string {
+	names := []string{}
+	if cfg.VaryByAccept {
+		names = append(names, "Accept")
+	}
+	if cfg.VaryByEncoding {
+		names = append(names, "Accept-Encoding")
+	}
+	if cfg.VaryByAuthPresence {
+		names = append(names, "Authorization")
+	}
+	names = append(names, cfg.VaryHeaders...)
+	return strings.Join(names, ", ")
+}
+
+// normalizeEncodings reduces an Accept-Encoding header to the known codings
+// it contains, in a fixed order, so equivalent headers share a cache entry
+func normalizeEncodings(header string) string {
+	header = strings.ToLower(header)
+	var b strings.Builder
+	for _, coding := range []string{"br", "gzip", "deflate"} {
+		if strings.Contains(header, coding) {
+			if b.Len() > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(coding)
+		}
+	}
+	return b.String()
+}
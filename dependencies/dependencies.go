@@ -0,0 +1,246 @@
+// Pine's upstream dependency health pinger
+// A Registry holds the upstreams an application depends on, databases behind
+// HTTP, payment providers, internal services, and checks them on an interval
+// through the cron system. Each dependency keeps a small circuit: after a
+// configurable number of consecutive failures it is marked unhealthy until a
+// ping succeeds again, so handlers can skip or degrade calls to a dependency
+// that is known to be down
+//
+//	deps := dependencies.New()
+//	deps.Add(dependencies.Dependency{Name: "payments", URL: "http://payments.internal/health"})
+//	deps.Start()
+//	deps.Register(app, "/readyz")
+//
+//	if !deps.Healthy("payments") { ... }
+
+package dependencies
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/cron"
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/BryanMwangi/pine/metrics"
+)
+
+// failures counts failed dependency pings, exported through the metrics
+// package
+var failures = metrics.NewCounter("dependency_failures")
+
+type Config struct {
+	// Interval is how often each dependency is pinged
+	//
+	// Default: 30 seconds
+	Interval time.Duration
+
+	// Timeout bounds a single ping
+	//
+	// Default: 5 seconds
+	Timeout time.Duration
+
+	// FailureThreshold is how many consecutive failed pings mark a
+	// dependency unhealthy, a single success closes the circuit again
+	//
+	// Default: 3
+	FailureThreshold int
+}
+
+// Dependency is one upstream to watch, give it either a URL or a Ping func
+type Dependency struct {
+	// Name identifies the dependency in readiness output and lookups
+	Name string
+
+	// URL is pinged with a GET request, any status below 500 counts as
+	// healthy. Ignored when Ping is set
+	URL string
+
+	// Ping replaces the HTTP check with your own, Eg: a database ping or
+	// a queue depth probe. Return an error when the dependency is down
+	Ping func() error
+}
+
+// Status is the externally visible state of one dependency
+type Status struct {
+	Healthy     bool      `json:"healthy"`
+	LastChecked time.Time `json:"last_checked"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// state is the internal bookkeeping behind a Status
+type state struct {
+	healthy     bool
+	consecutive int
+	lastChecked time.Time
+	lastError   string
+}
+
+// Registry watches a set of dependencies, create one with New
+type Registry struct {
+	cfg    Config
+	cron   *cron.Cron
+	client *http.Client
+
+	mu     sync.RWMutex
+	states map[string]*state
+}
+
+func New(config ...Config) *Registry {
+	cfg := Config{
+		Interval:         30 * time.Second,
+		Timeout:          5 * time.Second,
+		FailureThreshold: 3,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Interval > 0 {
+			cfg.Interval = userConfig.Interval
+		}
+		if userConfig.Timeout > 0 {
+			cfg.Timeout = userConfig.Timeout
+		}
+		if userConfig.FailureThreshold > 0 {
+			cfg.FailureThreshold = userConfig.FailureThreshold
+		}
+	}
+
+	return &Registry{
+		cfg:    cfg,
+		cron:   cron.New(),
+		client: &http.Client{Timeout: cfg.Timeout},
+		states: make(map[string]*state),
+	}
+}
+
+// Add registers dependencies and schedules their checks, call Start once
+// everything is registered. Dependencies start out healthy until the first
+// ping says otherwise
+func (r *Registry) Add(deps ...Dependency) {
+	for _, dep := range deps {
+		dep := dep
+		r.mu.Lock()
+		r.states[dep.Name] = &state{healthy: true}
+		r.mu.Unlock()
+
+		// the job never returns an error so the cron keeps it forever,
+		// failures are recorded in the dependency's state instead
+		r.cron.AddJobs(cron.Job{
+			Time: r.cfg.Interval,
+			Fn: func() error {
+				r.check(dep)
+				return nil
+			},
+		})
+	}
+}
+
+// Start begins pinging, checks run in the background through the cron system
+func (r *Registry) Start() {
+	r.cron.Start()
+}
+
+// Healthy reports the circuit state of one dependency, unknown names are
+// reported unhealthy so a typo fails loudly
+func (r *Registry) Healthy(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.states[name]
+	return ok && s.healthy
+}
+
+// Ready reports whether every registered dependency is healthy
+func (r *Registry) Ready() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.states {
+		if !s.healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Statuses returns a snapshot of every dependency's state
+func (r *Registry) Statuses() map[string]Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]Status, len(r.states))
+	for name, s := range r.states {
+		out[name] = Status{
+			Healthy:     s.healthy,
+			LastChecked: s.lastChecked,
+			LastError:   s.lastError,
+		}
+	}
+	return out
+}
+
+// ReadinessHandler serves the dependency states as JSON, 200 when all are
+// healthy and 503 otherwise, ready to wire into an orchestrator's readiness
+// probe
+func (r *Registry) ReadinessHandler() pine.Handler {
+	return func(c *pine.Ctx) error {
+		status := http.StatusOK
+		if !r.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(r.Statuses(), status)
+	}
+}
+
+// Register mounts the readiness handler on the server,
+// Eg: deps.Register(app, "/readyz")
+func (r *Registry) Register(server *pine.Server, path string) {
+	server.Get(path, r.ReadinessHandler())
+}
+
+// check runs one ping and updates the dependency's circuit
+func (r *Registry) check(dep Dependency) {
+	err := r.ping(dep)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.states[dep.Name]
+	s.lastChecked = time.Now()
+
+	if err == nil {
+		// one success closes the circuit
+		if !s.healthy {
+			logger.RuntimeInfo("dependency " + dep.Name + " is healthy again")
+		}
+		s.healthy = true
+		s.consecutive = 0
+		s.lastError = ""
+		return
+	}
+
+	failures.Inc()
+	s.consecutive++
+	s.lastError = err.Error()
+	if s.healthy && s.consecutive >= r.cfg.FailureThreshold {
+		s.healthy = false
+		logger.RuntimeError("dependency " + dep.Name + " marked unhealthy: " + err.Error())
+	}
+}
+
+// ping runs the custom Ping func when given, otherwise a GET on the URL
+func (r *Registry) ping(dep Dependency) error {
+	if dep.Ping != nil {
+		return dep.Ping()
+	}
+	resp, err := r.client.Get(dep.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Pine's request dump and replay tooling
+// This is a middleware that records full requests, headers and body included,
+// to a ring buffer of files on disk. Recorded requests can then be replayed
+// against the app through the replay endpoint or the ReplayFile helper to
+// reproduce production bugs locally
+
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+type Config struct {
+	// Dir is the directory the recorded requests are written to
+	//
+	// Default: "./dumps"
+	Dir string
+
+	// Max is the size of the ring buffer. Once Max requests have been
+	// recorded the oldest recording is overwritten
+	//
+	// Default: 100
+	Max int
+
+	// Filter decides whether a request should be recorded.
+	// Return false to skip a request
+	//
+	// Default: records every request
+	Filter func(c *pine.Ctx) bool
+}
+
+// recorder holds the ring buffer state shared by the middleware
+type recorder struct {
+	mu  sync.Mutex
+	seq int
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := defaults(config...)
+	rec := &recorder{}
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			if cfg.Filter == nil || cfg.Filter(c) {
+				if err := rec.record(c, cfg); err != nil {
+					// recording problems should never take down a request
+					logger.RuntimeError("dump: " + err.Error())
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func defaults(config ...Config) Config {
+	cfg := Config{
+		Dir: "./dumps",
+		Max: 100,
+	}
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Dir != "" {
+			cfg.Dir = userConfig.Dir
+		}
+		if userConfig.Max != 0 {
+			cfg.Max = userConfig.Max
+		}
+		if userConfig.Filter != nil {
+			cfg.Filter = userConfig.Filter
+		}
+	}
+	return cfg
+}
+
+// record writes the wire format of the request to the next slot in the ring
+func (rec *recorder) record(c *pine.Ctx, cfg Config) error {
+	// DumpRequest reads the body and puts it back so that handlers further
+	// down the stack can still bind it
+	raw, err := httputil.DumpRequest(c.Request, true)
+	if err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	slot := rec.seq % cfg.Max
+	rec.seq++
+	rec.mu.Unlock()
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Join(cfg.Dir, fmt.Sprintf("%06d.dump", slot))
+	return os.WriteFile(name, raw, 0644)
+}
+
+// RegisterReplay registers the replay endpoints on the server:
+//
+//	GET  /debug/dumps        lists the recorded dump files
+//	POST /debug/replay/:name replays a recording against the app itself
+//
+// The response of the replayed request is written back to the caller.
+// Only register these endpoints in development environments
+func RegisterReplay(server *pine.Server, config ...Config) {
+	cfg := defaults(config...)
+
+	server.Get("/debug/dumps", func(c *pine.Ctx) error {
+		entries, err := os.ReadDir(cfg.Dir)
+		if err != nil {
+			return c.SendStatus(http.StatusNotFound)
+		}
+		names := []string{}
+		for _, entry := range entries {
+			if filepath.Ext(entry.Name()) == ".dump" {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		return c.JSON(names)
+	})
+
+	server.Post("/debug/replay/:name", func(c *pine.Ctx) error {
+		req, err := ReadFile(filepath.Join(cfg.Dir, filepath.Base(c.Params("name"))))
+		if err != nil {
+			return c.SendStatus(http.StatusNotFound)
+		}
+		// dispatch the recorded request through the app itself and let the
+		// matched handler write the response to the caller
+		server.ServeHTTP(c.Response, req)
+		return nil
+	})
+}
+
+// ReadFile parses a recorded dump file back into a request that can be
+// dispatched through any http.Handler
+func ReadFile(path string) (*http.Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	req, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	// RequestURI must not be set on outgoing or re-dispatched requests
+	req.RequestURI = ""
+	req.URL.Scheme = "http"
+	req.URL.Host = req.Host
+	return req, nil
+}
+
+// ReplayFile replays a recorded dump file against the given base URL,
+// Eg: ReplayFile("./dumps/000001.dump", "http://localhost:3000")
+//
+// Useful as a small CLI for reproducing production recordings locally:
+//
+//	res, err := dump.ReplayFile(os.Args[1], os.Args[2])
+func ReplayFile(path, baseURL string) (*http.Response, error) {
+	req, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	base, err := req.URL.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme = base.Scheme
+	req.URL.Host = base.Host
+	return http.DefaultClient.Do(req)
+}
@@ -3,18 +3,116 @@ package pine
 import (
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"  // registered for image dimension checks
+	_ "image/jpeg" // registered for image dimension checks
+	_ "image/png"  // registered for image dimension checks
 	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/BryanMwangi/pine/storage"
 )
 
 var (
-	ErrFileName = errors.New("could not determine file name")
+	ErrFileName     = errors.New("could not determine file name")
+	ErrUploadType   = errors.New("upload type not allowed")
+	ErrImageBounds  = errors.New("image dimensions exceed the configured maximum")
+	ErrScanRejected = errors.New("upload rejected by scanner")
 )
 
+// Scanner vets an upload before it is persisted, Eg: by streaming it to a
+// virus scanning service. Return an error to reject the upload
+type Scanner interface {
+	Scan(name string, r io.Reader) error
+}
+
+// UploadConfig holds the validation applied to files saved with SaveFile
+// and SaveFileTo so that untrusted uploads can be vetted centrally
+type UploadConfig struct {
+	// AllowedTypes is an allow list of MIME types. The type of an upload is
+	// detected by sniffing its first 512 bytes, not by trusting the headers
+	// the client sent. An empty list allows every type
+	//
+	// Eg: []string{"image/png", "image/jpeg", "application/pdf"}
+	AllowedTypes []string
+
+	// MaxWidth and MaxHeight bound the dimensions of image uploads in
+	// pixels. Only checked for sniffed image types, 0 disables the check
+	MaxWidth, MaxHeight int
+
+	// Scanner is invoked with the full upload before it is persisted
+	//
+	// Default: nil
+	Scanner Scanner
+}
+
+// validateUpload runs the configured validation hooks against an upload.
+// The file is rewound after every check so that it can still be persisted
+func (c *Ctx) validateUpload(file multipart.File, fh *multipart.FileHeader) error {
+	if c.Server == nil {
+		return nil
+	}
+	cfg := c.Server.config.Upload
+
+	if len(cfg.AllowedTypes) > 0 || cfg.MaxWidth > 0 || cfg.MaxHeight > 0 {
+		// sniff the real content type from the first 512 bytes
+		head := make([]byte, 512)
+		n, err := file.Read(head)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		detected := http.DetectContentType(head[:n])
+		// DetectContentType appends parameters such as charset
+		if idx := strings.Index(detected, ";"); idx != -1 {
+			detected = detected[:idx]
+		}
+
+		if len(cfg.AllowedTypes) > 0 {
+			allowed := false
+			for _, t := range cfg.AllowedTypes {
+				if t == detected {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return ErrUploadType
+			}
+		}
+
+		if (cfg.MaxWidth > 0 || cfg.MaxHeight > 0) && strings.HasPrefix(detected, "image/") {
+			bounds, _, err := image.DecodeConfig(file)
+			if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+				return seekErr
+			}
+			if err != nil {
+				return ErrUploadType
+			}
+			if (cfg.MaxWidth > 0 && bounds.Width > cfg.MaxWidth) ||
+				(cfg.MaxHeight > 0 && bounds.Height > cfg.MaxHeight) {
+				return ErrImageBounds
+			}
+		}
+	}
+
+	if cfg.Scanner != nil {
+		if err := cfg.Scanner.Scan(fh.Filename, file); err != nil {
+			return ErrScanRejected
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Ctx) FormFile(key string) (multipart.File, *multipart.FileHeader, error) {
 	return c.Request.FormFile(key)
 }
@@ -22,6 +120,11 @@ func (c *Ctx) FormFile(key string) (multipart.File, *multipart.FileHeader, error
 func (c *Ctx) SaveFile(file multipart.File, fh *multipart.FileHeader) error {
 	defer file.Close() // Ensure the file is closed after all operations.
 
+	// Vet the upload before persisting it, see UploadConfig
+	if err := c.validateUpload(file, fh); err != nil {
+		return err
+	}
+
 	// Extract filename from header directly, which is more reliable.
 	fileName := fh.Filename
 	if fileName == "" {
@@ -62,7 +165,80 @@ func (c *Ctx) SaveFile(file multipart.File, fh *multipart.FileHeader) error {
 	return nil
 }
 
+// SaveFileTo streams an uploaded file to the given storage backend without
+// touching local disk. The key is the object key in the store, pass an empty
+// key to use the uploaded file name
+//
+// Eg: c.SaveFileTo(file, fh, myS3Backend, "avatars/"+userID)
+func (c *Ctx) SaveFileTo(file multipart.File, fh *multipart.FileHeader, backend storage.Backend, key string) error {
+	defer file.Close() // Ensure the file is closed after all operations.
+
+	if key == "" {
+		key = fh.Filename
+	}
+	if key == "" {
+		return ErrFileName
+	}
+
+	// Vet the upload before persisting it, see UploadConfig
+	if err := c.validateUpload(file, fh); err != nil {
+		return err
+	}
+
+	return backend.Put(key, file, fh.Size, fh.Header.Get("Content-Type"))
+}
+
+// ensureFormParsed lazily parses the form the first time a form helper is
+// used on a request. Parsing only ever happens once per request
+func (c *Ctx) ensureFormParsed() {
+	if c.Request.Form != nil {
+		return
+	}
+	bodyLimit := int64(DefaultBodyLimit)
+	if c.Server != nil {
+		bodyLimit = c.Server.config.BodyLimit
+	}
+	contentType := c.Request.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		c.Request.ParseMultipartForm(bodyLimit)
+		return
+	}
+	c.Request.ParseForm()
+}
+
+// FormValue returns the form value for the given key.
+// This covers query parameters, urlencoded and multipart form bodies.
+// You can pass a default that is returned when the key is not set
+// Eg: c.FormValue("page", "1")
+func (c *Ctx) FormValue(key string, defaultValue ...string) string {
+	c.ensureFormParsed()
+	if value := c.Request.FormValue(key); value != "" {
+		return value
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return ""
+}
+
+// FormValues returns all values submitted under the given key, useful for
+// multi select inputs and repeated fields
+// Returns nil if the key is not set
+func (c *Ctx) FormValues(key string) []string {
+	c.ensureFormParsed()
+	if values, ok := c.Request.Form[key]; ok {
+		return values
+	}
+	if c.Request.MultipartForm != nil {
+		if values, ok := c.Request.MultipartForm.Value[key]; ok {
+			return values
+		}
+	}
+	return nil
+}
+
 func (c *Ctx) MultipartForm() *multipart.Form {
+	c.ensureFormParsed()
 	return c.Request.MultipartForm
 }
 
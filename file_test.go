@@ -123,3 +123,37 @@ func TestSaveFile(t *testing.T) {
 // 		t.Errorf("Streamed file content mismatch. Got: %s, Expected: %s", body, fileContent)
 // 	}
 // }
+
+func TestFormValue_WithDefault(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/submit",
+		bytes.NewBufferString("name=pine&tag=a&tag=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ctx := &Ctx{Request: req}
+
+	if got := ctx.FormValue("name"); got != "pine" {
+		t.Errorf("expected 'pine', got '%s'", got)
+	}
+	if got := ctx.FormValue("missing", "fallback"); got != "fallback" {
+		t.Errorf("expected 'fallback', got '%s'", got)
+	}
+	if got := ctx.FormValue("missing"); got != "" {
+		t.Errorf("expected empty string, got '%s'", got)
+	}
+}
+
+func TestFormValues_MultipleValues(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/submit",
+		bytes.NewBufferString("tag=a&tag=b"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ctx := &Ctx{Request: req}
+
+	values := ctx.FormValues("tag")
+	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Errorf("expected [a b], got %v", values)
+	}
+	if values := ctx.FormValues("missing"); values != nil {
+		t.Errorf("expected nil, got %v", values)
+	}
+}
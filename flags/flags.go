@@ -0,0 +1,247 @@
+// Pine's feature flag integration
+// A Provider holds the flag definitions and the middleware makes them
+// available to handlers through c.FlagEnabled. Flags can be fully on or
+// rolled out to a percentage of traffic, bucketing is stable per user or
+// IP so the same client always sees the same variant
+//
+//	provider := flags.NewMemory()
+//	provider.Set("new-checkout", flags.Flag{Percentage: 25})
+//	app.Use(flags.New(provider))
+//
+//	if c.FlagEnabled("new-checkout") { ... }
+
+package flags
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Flag is a single feature flag definition
+type Flag struct {
+	// Enabled turns the flag fully on regardless of Percentage
+	Enabled bool `json:"enabled"`
+
+	// Percentage rolls the flag out to this share of traffic, 0 to 100.
+	// Ignored when Enabled is true
+	Percentage int `json:"percentage"`
+}
+
+// Provider looks up flag definitions by name
+type Provider interface {
+	Flag(name string) (Flag, bool)
+}
+
+type Config struct {
+	// KeyGen derives the bucketing key for percentage rollouts, return a
+	// user id for per user stability
+	//
+	// Default: the client IP
+	KeyGen func(c *pine.Ctx) string
+}
+
+// New returns the middleware that installs the provider for c.FlagEnabled
+func New(provider Provider, config ...Config) pine.Middleware {
+	cfg := Config{
+		KeyGen: func(c *pine.Ctx) string { return c.IP() },
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 && config[0].KeyGen != nil {
+		cfg.KeyGen = config[0].KeyGen
+	}
+
+	evaluator := &evaluator{provider: provider, keyGen: cfg.KeyGen}
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			c.Locals(pine.FlagsKey, evaluator)
+			return next(c)
+		}
+	}
+}
+
+// evaluator implements pine.FlagProvider on top of a Provider
+type evaluator struct {
+	provider Provider
+	keyGen   func(c *pine.Ctx) string
+}
+
+func (e *evaluator) Enabled(c *pine.Ctx, name string) bool {
+	flag, ok := e.provider.Flag(name)
+	if !ok {
+		return false
+	}
+	if flag.Enabled {
+		return true
+	}
+	if flag.Percentage <= 0 {
+		return false
+	}
+	if flag.Percentage >= 100 {
+		return true
+	}
+	return bucket(e.keyGen(c), name) < flag.Percentage
+}
+
+// bucket maps a key and flag name to a stable number between 0 and 99
+func bucket(key, name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte(":"))
+	h.Write([]byte(name))
+	return int(h.Sum32() % 100)
+}
+
+// Memory is an in-memory provider, useful for tests and flags toggled at
+// runtime through your own admin endpoints
+type Memory struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+func NewMemory() *Memory {
+	return &Memory{flags: make(map[string]Flag)}
+}
+
+func (m *Memory) Flag(name string) (Flag, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	flag, ok := m.flags[name]
+	return flag, ok
+}
+
+func (m *Memory) Set(name string, flag Flag) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags[name] = flag
+}
+
+func (m *Memory) Delete(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.flags, name)
+}
+
+// fileProvider reads flags from a JSON file and reloads it on an interval
+type fileProvider struct {
+	path     string
+	interval time.Duration
+
+	mu     sync.RWMutex
+	flags  map[string]Flag
+	loaded time.Time
+}
+
+// NewFile returns a provider backed by a JSON file mapping flag names to
+// definitions. The file is re-read once per interval so edits apply
+// without a restart
+func NewFile(path string, interval time.Duration) Provider {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	p := &fileProvider{path: path, interval: interval, flags: make(map[string]Flag)}
+	p.reload()
+	return p
+}
+
+func (p *fileProvider) Flag(name string) (Flag, bool) {
+	p.mu.RLock()
+	stale := time.Since(p.loaded) > p.interval
+	flag, ok := p.flags[name]
+	p.mu.RUnlock()
+
+	if stale {
+		p.reload()
+		p.mu.RLock()
+		flag, ok = p.flags[name]
+		p.mu.RUnlock()
+	}
+	return flag, ok
+}
+
+func (p *fileProvider) reload() {
+	raw, err := os.ReadFile(p.path)
+	if err != nil {
+		logger.RuntimeError("failed to read flag file: " + err.Error())
+		return
+	}
+	flags := make(map[string]Flag)
+	if err := json.Unmarshal(raw, &flags); err != nil {
+		logger.RuntimeError("failed to parse flag file: " + err.Error())
+		return
+	}
+	p.mu.Lock()
+	p.flags = flags
+	p.loaded = time.Now()
+	p.mu.Unlock()
+}
+
+// remoteProvider polls an HTTP endpoint serving the same JSON shape as the
+// file provider
+type remoteProvider struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu     sync.RWMutex
+	flags  map[string]Flag
+	loaded time.Time
+}
+
+// NewRemote returns a provider that polls a flag service,
+// Eg: NewRemote("https://flags.internal/myapp.json", time.Minute)
+func NewRemote(url string, interval time.Duration) Provider {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	p := &remoteProvider{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		flags:    make(map[string]Flag),
+	}
+	p.reload()
+	return p
+}
+
+func (p *remoteProvider) Flag(name string) (Flag, bool) {
+	p.mu.RLock()
+	stale := time.Since(p.loaded) > p.interval
+	flag, ok := p.flags[name]
+	p.mu.RUnlock()
+
+	if stale {
+		p.reload()
+		p.mu.RLock()
+		flag, ok = p.flags[name]
+		p.mu.RUnlock()
+	}
+	return flag, ok
+}
+
+func (p *remoteProvider) reload() {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		logger.RuntimeError("failed to fetch flags: " + err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	flags := make(map[string]Flag)
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		logger.RuntimeError("failed to parse flags: " + err.Error())
+		return
+	}
+	p.mu.Lock()
+	p.flags = flags
+	p.loaded = time.Now()
+	p.mu.Unlock()
+}
@@ -9,4 +9,7 @@ require (
 	github.com/gorilla/websocket v1.5.3
 )
 
-require golang.org/x/sys v0.26.0 // indirect
+require (
+	golang.org/x/net v0.26.0
+	golang.org/x/sys v0.26.0 // indirect
+)
@@ -1,9 +1,12 @@
 package helmet
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/logger"
 )
 
 type Config struct {
@@ -32,6 +35,19 @@ type Config struct {
 	// Optional. Default value "".
 	ContentSecurityPolicy string
 
+	// CSPReportOnly
+	// Optional. Default value false.
+	// When true the ContentSecurityPolicy is sent as
+	// Content-Security-Policy-Report-Only so violations are reported by
+	// browsers without being enforced
+	CSPReportOnly bool
+
+	// CSPReportURI
+	// Optional. Default value "".
+	// When set a report-uri directive pointing here is appended to the
+	// policy. Pair this with RegisterReports to capture violations
+	CSPReportURI string
+
 	// ReferrerPolicy
 	// Optional. Default value "ReferrerPolicy".
 	ReferrerPolicy string
@@ -107,6 +123,14 @@ func New(config ...Config) pine.Middleware {
 			cfg.ContentSecurityPolicy = useConfig.ContentSecurityPolicy
 		}
 
+		if useConfig.CSPReportOnly {
+			cfg.CSPReportOnly = useConfig.CSPReportOnly
+		}
+
+		if useConfig.CSPReportURI != "" {
+			cfg.CSPReportURI = useConfig.CSPReportURI
+		}
+
 		if useConfig.ReferrerPolicy != "" {
 			cfg.ReferrerPolicy = useConfig.ReferrerPolicy
 		}
@@ -165,7 +189,15 @@ func SetHelmet(c *pine.Ctx, cfg Config) *pine.Ctx {
 		c.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
 	}
 	if cfg.ContentSecurityPolicy != "" {
-		c.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		policy := cfg.ContentSecurityPolicy
+		if cfg.CSPReportURI != "" {
+			policy += "; report-uri " + cfg.CSPReportURI
+		}
+		if cfg.CSPReportOnly {
+			c.Set("Content-Security-Policy-Report-Only", policy)
+		} else {
+			c.Set("Content-Security-Policy", policy)
+		}
 	}
 	if cfg.ReferrerPolicy != "" {
 		c.Set("Referrer-Policy", cfg.ReferrerPolicy)
@@ -193,3 +225,100 @@ func SetHelmet(c *pine.Ctx, cfg Config) *pine.Ctx {
 	}
 	return c
 }
+
+// Disabled removes a header that the global helmet middleware set when used
+// as a value in an Override config
+const Disabled = "-"
+
+// Override returns a middleware for individual routes that replaces headers
+// the global helmet middleware set. Only the fields you fill in are touched,
+// use Disabled to drop a header entirely,
+// Eg: relax framing for an embeddable widget:
+//
+//	app.Get("/widget", widgetHandler,
+//		helmet.Override(helmet.Config{XFrameOptions: helmet.Disabled}))
+func Override(cfg Config) pine.Middleware {
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			overrideHeader(c, "X-XSS-Protection", cfg.XSSProtection)
+			overrideHeader(c, "X-Content-Type-Options", cfg.ContentTypeNosniff)
+			overrideHeader(c, "X-Frame-Options", cfg.XFrameOptions)
+			overrideHeader(c, "Referrer-Policy", cfg.ReferrerPolicy)
+			overrideHeader(c, "Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy)
+			overrideHeader(c, "Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy)
+			overrideHeader(c, "Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy)
+			overrideHeader(c, "Origin-Agent-Cluster", cfg.OriginAgentCluster)
+			overrideHeader(c, "X-DNS-Prefetch-Control", cfg.XDNSPrefetchControl)
+			overrideHeader(c, "X-Download-Options", cfg.XDownloadOptions)
+			overrideHeader(c, "X-Permitted-Cross-Domain-Policies", cfg.XPermittedCrossDomain)
+			if cfg.HSTSMaxAge != 0 {
+				c.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", cfg.HSTSMaxAge))
+			}
+			if cfg.ContentSecurityPolicy != "" {
+				if cfg.ContentSecurityPolicy == Disabled {
+					c.Response.Header().Del("Content-Security-Policy")
+					c.Response.Header().Del("Content-Security-Policy-Report-Only")
+				} else {
+					policy := cfg.ContentSecurityPolicy
+					if cfg.CSPReportURI != "" {
+						policy += "; report-uri " + cfg.CSPReportURI
+					}
+					if cfg.CSPReportOnly {
+						c.Response.Header().Del("Content-Security-Policy")
+						c.Set("Content-Security-Policy-Report-Only", policy)
+					} else {
+						c.Set("Content-Security-Policy", policy)
+					}
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func overrideHeader(c *pine.Ctx, name, value string) {
+	if value == "" {
+		return
+	}
+	if value == Disabled {
+		c.Response.Header().Del(name)
+		return
+	}
+	c.Set(name, value)
+}
+
+// Report is a single CSP violation as posted by the browser
+type Report struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+}
+
+// ReportHandler returns a handler that accepts CSP violation reports. Each
+// report is logged and passed to onReport when one is provided
+func ReportHandler(onReport ...func(Report)) pine.Handler {
+	return func(c *pine.Ctx) error {
+		var body struct {
+			Report Report `json:"csp-report"`
+		}
+		if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+		logger.RuntimeInfo("CSP violation on " + body.Report.DocumentURI + ": " + body.Report.ViolatedDirective + " blocked " + body.Report.BlockedURI)
+		if len(onReport) > 0 && onReport[0] != nil {
+			onReport[0](body.Report)
+		}
+		return c.SendStatus(http.StatusNoContent)
+	}
+}
+
+// RegisterReports wires ReportHandler at the given path, point CSPReportURI
+// here to capture violations
+func RegisterReports(server *pine.Server, path string, onReport ...func(Report)) {
+	server.Post(path, ReportHandler(onReport...))
+}
@@ -15,8 +15,12 @@ import (
 
 	"github.com/BryanMwangi/pine"
 	"github.com/BryanMwangi/pine/cache"
+	"github.com/BryanMwangi/pine/metrics"
 )
 
+// rejections counts blocked requests, exported through the metrics package
+var rejections = metrics.NewCounter("limiter_rejections")
+
 type Config struct {
 	// Defines the maximum number of requests a client can make within a specified time
 	// window
@@ -48,6 +52,14 @@ type Config struct {
 	// Default: returns the IP address of the client
 	KeyGen func(c *pine.Ctx) string
 
+	// Defines a function that resolves the limit and window per request.
+	// Use this to give authenticated plans different quotas, Eg: read the
+	// user's plan from the locals and return its limits. MaxRequests and
+	// Window are used when this returns a non positive limit
+	//
+	// Default: nil
+	QuotaResolver func(c *pine.Ctx) (limit int, window time.Duration)
+
 	// Defines a list of IP addresses or user ids that are allowed to make requests.
 	// Any IP address defined in the whitelist will be allowed to make requests beyond
 	// the rate limit.
@@ -79,6 +91,7 @@ type entry struct {
 	mu        sync.Mutex
 	key       string
 	count     int
+	limit     int
 	reset     time.Time
 	remaining int
 }
@@ -131,6 +144,9 @@ func New(config ...Config) pine.Middleware {
 		if userConfig.KeyGen != nil {
 			cfg.KeyGen = userConfig.KeyGen
 		}
+		if userConfig.QuotaResolver != nil {
+			cfg.QuotaResolver = userConfig.QuotaResolver
+		}
 		if userConfig.Whitelist != nil {
 			for _, w := range userConfig.Whitelist {
 				cfg.internalWhitelist[w] = struct{}{}
@@ -157,7 +173,7 @@ func New(config ...Config) pine.Middleware {
 				var reset string
 
 				if e != nil {
-					maxrequest = cfg.MaxRequests
+					maxrequest = e.limit
 					remaining = e.remaining
 					reset = e.reset.Format(http.TimeFormat)
 				} else {
@@ -172,6 +188,7 @@ func New(config ...Config) pine.Middleware {
 			}
 			// IP is blacklisted
 			if err == ErrBlacklist {
+				rejections.Inc()
 				return cfg.Handler(c)
 			}
 
@@ -182,6 +199,7 @@ func New(config ...Config) pine.Middleware {
 
 			// IP is rate limited. Rate limit is exceeded
 			if e.remaining == 0 {
+				rejections.Inc()
 				return cfg.Handler(c)
 			}
 			return next(c)
@@ -206,6 +224,18 @@ func (cfg *Config) process(c *pine.Ctx) (*entry, error) {
 		}
 	}
 
+	// resolve the quota for this request, authenticated plans can carry
+	// bigger limits than the configured defaults
+	limit, window := cfg.MaxRequests, cfg.Window
+	if cfg.QuotaResolver != nil {
+		if l, w := cfg.QuotaResolver(c); l > 0 {
+			limit = l
+			if w > 0 {
+				window = w
+			}
+		}
+	}
+
 	// store is memory safe and thread safe
 	ent := cfg.store.Get(key)
 
@@ -214,10 +244,11 @@ func (cfg *Config) process(c *pine.Ctx) (*entry, error) {
 		e := &entry{
 			key:       key,
 			count:     1,
-			reset:     time.Now().Add(cfg.Window),
-			remaining: cfg.MaxRequests,
+			limit:     limit,
+			reset:     time.Now().Add(window),
+			remaining: limit,
 		}
-		cfg.store.Set(key, e, cfg.Window)
+		cfg.store.Set(key, e, window)
 		return e, nil
 	}
 	// we convert the entry to the rate limit entry
@@ -0,0 +1,84 @@
+package pine
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrRouteNotFound = errors.New("no route registered under that name")
+)
+
+// Link is a single entry of a _links object
+type Link struct {
+	Href string `json:"href"`
+}
+
+// Links maps relation names such as "self", "next" and "prev" to links
+type Links map[string]Link
+
+// URLFor rebuilds the path of a named route, replacing its parameters with
+// the given values. This is the reverse of route matching
+// Eg: a route registered as app.Get("/users/:id", h).As("user") gives
+// app.URLFor("user", map[string]string{"id": "42"}) == "/users/42"
+func (server *Server) URLFor(name string, params map[string]string) (string, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, routes := range server.stack {
+		for _, route := range routes {
+			if route.Name != name {
+				continue
+			}
+			segments := splitPath(route.Path)
+			for i, segment := range segments {
+				if len(segment) > 0 && segment[0] == ':' {
+					segments[i] = params[segment[1:]]
+				}
+			}
+			return "/" + strings.Join(segments, "/"), nil
+		}
+	}
+	return "", ErrRouteNotFound
+}
+
+// LinkTo is like URLFor but returns a Link ready to be put into a Links map.
+// Unresolvable names yield an empty href instead of an error so that link
+// building stays chainable in handlers
+func (server *Server) LinkTo(name string, params map[string]string) Link {
+	href, err := server.URLFor(name, params)
+	if err != nil {
+		return Link{}
+	}
+	return Link{Href: href}
+}
+
+// JSONWithLinks writes a JSON response with a HATEOAS style _links object
+// attached, Eg:
+//
+//	return c.JSONWithLinks(user, pine.Links{
+//		"self": c.Server.LinkTo("user", map[string]string{"id": id}),
+//		"next": c.Server.LinkTo("user", map[string]string{"id": next}),
+//	})
+//
+// The data must encode to a JSON object for the links to be attached
+func (c *Ctx) JSONWithLinks(data interface{}, links Links, status ...int) error {
+	raw, err := c.Server.config.JSONEncoder(data)
+	if err != nil {
+		return err
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		// not an object, serve the data untouched
+		return c.JSON(data, status...)
+	}
+
+	rawLinks, err := json.Marshal(links)
+	if err != nil {
+		return err
+	}
+	payload["_links"] = rawLinks
+	return c.JSON(payload, status...)
+}
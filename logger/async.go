@@ -0,0 +1,117 @@
+// Async console writes
+// EnableAsync routes console output through an in-memory ring buffer that a
+// background goroutine flushes in batches, so a busy request path pays for
+// an append instead of a write syscall per log line. The ring drops the
+// oldest line when it is full, a slow terminal can therefore never stall
+// request handling. Call Flush before exiting so buffered lines are not
+// lost, ServeShutDown does this for you
+
+package logger
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// how often the background writer drains the ring even when no line woke it
+const asyncFlushInterval = 100 * time.Millisecond
+
+type asyncWriter struct {
+	mu    sync.Mutex
+	lines [][]byte
+	max   int
+	wake  chan struct{}
+}
+
+var (
+	asyncMu sync.Mutex
+	async   *asyncWriter
+)
+
+// EnableAsync switches console output to buffered background writes,
+// buffer is the maximum number of lines held before the oldest is dropped.
+// Calling it twice has no effect
+func EnableAsync(buffer int) {
+	if buffer <= 0 {
+		buffer = 1024
+	}
+	asyncMu.Lock()
+	defer asyncMu.Unlock()
+	if async != nil {
+		return
+	}
+	async = &asyncWriter{
+		max:  buffer,
+		wake: make(chan struct{}, 1),
+	}
+	go async.run()
+}
+
+// Flush writes everything still sitting in the async buffer to stdout,
+// call it before the process exits. A no-op when async mode is off
+func Flush() {
+	if w := currentAsync(); w != nil {
+		w.flush()
+	}
+}
+
+// currentAsync returns the active writer, nil when async mode is off
+func currentAsync() *asyncWriter {
+	asyncMu.Lock()
+	defer asyncMu.Unlock()
+	return async
+}
+
+// consoleWrite sends one finished console line to stdout, through the
+// async buffer when one is enabled
+func consoleWrite(line []byte) {
+	if w := currentAsync(); w != nil {
+		w.enqueue(line)
+		return
+	}
+	os.Stdout.Write(line)
+}
+
+// enqueue appends a line to the ring, dropping the oldest when full
+func (w *asyncWriter) enqueue(line []byte) {
+	w.mu.Lock()
+	if len(w.lines) >= w.max {
+		w.lines = w.lines[1:]
+	}
+	w.lines = append(w.lines, line)
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run drains the ring whenever a line arrives or the interval elapses
+func (w *asyncWriter) run() {
+	ticker := time.NewTicker(asyncFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.wake:
+		case <-ticker.C:
+		}
+		w.flush()
+	}
+}
+
+// flush writes the buffered lines as one batch
+func (w *asyncWriter) flush() {
+	w.mu.Lock()
+	if len(w.lines) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	batch := bytes.Join(w.lines, nil)
+	w.lines = nil
+	w.mu.Unlock()
+
+	os.Stdout.Write(batch)
+}
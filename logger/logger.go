@@ -30,6 +30,60 @@ var (
 	White  = "\033[97m"
 )
 
+// FormatText and FormatJSON are the console output formats, see SetFormat
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// console output settings. Colors are skipped automatically when stdout is
+// not a terminal or the NO_COLOR convention is set, so files and log
+// aggregators never see ANSI escape codes
+var (
+	noColor = !isTerminal(os.Stdout) || os.Getenv("NO_COLOR") != ""
+	format  = FormatText
+)
+
+// SetNoColor overrides the automatic terminal detection, pass true to
+// strip colors and false to force them back on
+func SetNoColor(disabled bool) {
+	noColor = disabled
+}
+
+// SetFormat selects the console output format. FormatJSON wraps every line
+// in a small time and level tagged envelope for log aggregators, anything
+// else falls back to FormatText
+func SetFormat(f string) {
+	if f == FormatJSON {
+		format = FormatJSON
+		return
+	}
+	format = FormatText
+}
+
+// isTerminal reports whether f is an interactive terminal, ANSI colors are
+// only worth emitting when it is
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// console writes one line to stdout honouring the color and format settings
+func console(color, level, message string) {
+	if format == FormatJSON {
+		consoleWrite(consoleJSON(level, message))
+		return
+	}
+	if noColor {
+		consoleWrite([]byte(message + "\n"))
+		return
+	}
+	consoleWrite([]byte(color + message + Reset + "\n"))
+}
+
 // we initialise this in the target project by calling logger.init and passing in
 // the parameters required to store the log data
 func Init(fileName string, maxSize int64) error {
@@ -100,29 +154,35 @@ func (l *logger) openNew() error {
 }
 
 func Info(message interface{}) {
-	fmt.Println(White + message.(string) + Reset)
+	console(White, "INFO", message.(string))
 	log.Println("INFO: " + message.(string))
+	emit("INFO", message.(string))
 }
 
 func Error(message interface{}) {
-	fmt.Println(Red + message.(string) + Reset)
+	console(Red, "ERROR", message.(string))
 	log.Println("ERROR: " + message.(string))
+	emit("ERROR", message.(string))
 }
 
 func Warning(message interface{}) {
-	fmt.Println(Yellow + message.(string) + Reset)
+	console(Yellow, "WARN", message.(string))
 	log.Println("WARN : " + message.(string))
+	emit("WARN", message.(string))
 }
 
 func Success(message interface{}) {
-	fmt.Println(Green + message.(string) + Reset)
+	console(Green, "SUCCESS", message.(string))
 	log.Println("SUCCESS: " + message.(string))
+	emit("SUCCESS", message.(string))
 }
 
 func RuntimeError(message interface{}) {
-	fmt.Println(Red + message.(string) + Reset)
+	console(Red, "ERROR", message.(string))
+	emit("ERROR", message.(string))
 }
 
 func RuntimeInfo(message interface{}) {
-	fmt.Println(White + message.(string) + Reset)
+	console(White, "INFO", message.(string))
+	emit("INFO", message.(string))
 }
@@ -0,0 +1,279 @@
+package logger
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink receives finished log lines and ships them somewhere. Register one
+// with AddSink and every line logged through this package is forwarded,
+// so access and application logs can be centralized without sidecars
+type Sink interface {
+	Write(line []byte) error
+	Close() error
+}
+
+var (
+	sinksMu sync.Mutex
+	sinks   []Sink
+)
+
+// AddSink registers a sink, every line logged after this call is forwarded
+// to it in addition to the usual outputs
+func AddSink(s Sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks = append(sinks, s)
+}
+
+// CloseSinks flushes and closes all registered sinks, call this on
+// shutdown so buffered lines are not lost
+func CloseSinks() {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	for _, s := range sinks {
+		s.Close()
+	}
+	sinks = nil
+}
+
+// emit forwards a line to every registered sink. Failing sinks are skipped
+// silently, logging about logging would loop forever
+func emit(level, message string) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if len(sinks) == 0 {
+		return
+	}
+	line := []byte(time.Now().Format(time.RFC3339) + " " + level + ": " + message + "\n")
+	for _, s := range sinks {
+		s.Write(line)
+	}
+}
+
+// fileSink appends lines to a file
+type fileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// FileSink ships lines to a file opened in append mode
+func FileSink(path string) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: file}, nil
+}
+
+func (s *fileSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.file.Write(line)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// stdoutJSONSink wraps each line in a small JSON envelope
+type stdoutJSONSink struct {
+	mu sync.Mutex
+}
+
+// StdoutJSON ships lines to stdout as one JSON object per line, the shape
+// log collectors expect from containers
+func StdoutJSON() Sink {
+	return &stdoutJSONSink{}
+}
+
+func (s *stdoutJSONSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	encoded, err := jsonLine(bytes.TrimRight(line, "\n"))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(encoded)
+	return err
+}
+
+func (s *stdoutJSONSink) Close() error {
+	return nil
+}
+
+// syslogSink ships lines to a syslog daemon over the network. A plain
+// connection is used instead of log/syslog so the sink builds everywhere
+type syslogSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	tag  string
+}
+
+// SyslogSink ships lines to a syslog daemon,
+// Eg: SyslogSink("udp", "127.0.0.1:514", "myapp")
+func SyslogSink(network, address, tag string) (Sink, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{conn: conn, tag: tag}, nil
+}
+
+func (s *syslogSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// priority 14 is facility user, severity info
+	_, err := s.conn.Write(append([]byte("<14>"+s.tag+": "), line...))
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// httpSink ships lines in bulk to an HTTP endpoint such as Loki's push API
+type httpSink struct {
+	url    string
+	client *http.Client
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// httpFlushSize is the buffered byte count that triggers a bulk POST
+const httpFlushSize = 64 * 1024
+
+// HTTPSink ships lines to a bulk HTTP endpoint. Lines are buffered and
+// POSTed newline delimited once enough accumulate or Close is called
+func HTTPSink(url string) Sink {
+	return &httpSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *httpSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Write(line)
+	if s.buf.Len() >= httpFlushSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// flush must be called with the mutex held
+func (s *httpSink) flush() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	body := make([]byte, s.buf.Len())
+	copy(body, s.buf.Bytes())
+	s.buf.Reset()
+
+	resp, err := s.client.Post(s.url, "text/plain", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// bufferedSink decouples shipping from the request path through a channel
+type bufferedSink struct {
+	inner Sink
+	lines chan []byte
+	done  chan struct{}
+}
+
+// Buffered wraps a sink so writes never block the caller. Lines queue in a
+// channel drained by a background goroutine and are dropped once the queue
+// is full, slow sinks must not stall request handling
+func Buffered(inner Sink, size int) Sink {
+	if size <= 0 {
+		size = 1024
+	}
+	s := &bufferedSink{
+		inner: inner,
+		lines: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+	go s.drain()
+	return s
+}
+
+func (s *bufferedSink) drain() {
+	for line := range s.lines {
+		s.inner.Write(line)
+	}
+	close(s.done)
+}
+
+func (s *bufferedSink) Write(line []byte) error {
+	// the line is copied as callers may reuse the slice
+	buf := make([]byte, len(line))
+	copy(buf, line)
+	select {
+	case s.lines <- buf:
+	default:
+		// queue full, apply backpressure by dropping instead of blocking
+	}
+	return nil
+}
+
+func (s *bufferedSink) Close() error {
+	close(s.lines)
+	<-s.done
+	return s.inner.Close()
+}
+
+// jsonLine builds the envelope for StdoutJSON without pulling in
+// encoding/json on the hot path
+func jsonLine(msg []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(`{"time":"` + time.Now().Format(time.RFC3339) + `","log":"`)
+	escapeJSON(&out, msg)
+	out.WriteString("\"}\n")
+	return out.Bytes(), nil
+}
+
+// consoleJSON builds the level tagged envelope used by the JSON console
+// format, see SetFormat
+func consoleJSON(level, message string) []byte {
+	var out bytes.Buffer
+	out.WriteString(`{"time":"` + time.Now().Format(time.RFC3339) + `","level":"` + level + `","log":"`)
+	escapeJSON(&out, []byte(message))
+	out.WriteString("\"}\n")
+	return out.Bytes()
+}
+
+// escapeJSON writes msg into out with the characters that would break a
+// JSON string escaped
+func escapeJSON(out *bytes.Buffer, msg []byte) {
+	for _, b := range msg {
+		switch b {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			out.WriteByte(b)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Pine's metrics package
+// Subsystems export internal counters here so their health is visible at
+// runtime, not just HTTP stats. The cache counts hits, misses and
+// evictions, the limiter counts rejections, cron counts failed jobs and
+// the websocket package tracks open connections
+//
+// Expose everything as JSON with Register:
+//
+//	metrics.Register(app, "/metrics")
+
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Counter is a monotonically increasing value, use Add with a negative
+// delta for gauges such as open connections
+type Counter struct {
+	value int64
+}
+
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+func (c *Counter) Add(delta int64) {
+	atomic.AddInt64(&c.value, delta)
+}
+
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+var (
+	mu       sync.Mutex
+	counters = make(map[string]*Counter)
+)
+
+// NewCounter returns the counter registered under name, creating it on
+// first use so subsystems and apps can share counters by name
+func NewCounter(name string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+	if counter, ok := counters[name]; ok {
+		return counter
+	}
+	counter := &Counter{}
+	counters[name] = counter
+	return counter
+}
+
+// Snapshot returns the current value of every registered counter
+func Snapshot() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	snapshot := make(map[string]int64, len(counters))
+	for name, counter := range counters {
+		snapshot[name] = counter.Value()
+	}
+	return snapshot
+}
+
+// Handler serves the snapshot as JSON
+func Handler() pine.Handler {
+	return func(c *pine.Ctx) error {
+		return c.JSON(Snapshot())
+	}
+}
+
+// Register wires Handler at the given path,
+// Eg: metrics.Register(app, "/metrics")
+func Register(server *pine.Server, path string) {
+	server.Get(path, Handler())
+}
@@ -0,0 +1,72 @@
+// Response diffing for shadow traffic
+// With Compare enabled every mirrored request's shadow response is diffed
+// against the primary one, so a rewrite can be validated field by field
+// with production traffic before the switch. Status codes are compared
+// directly and JSON bodies are compared after normalization, key order
+// and whitespace differences therefore never count as a mismatch.
+// Mismatches are reported through the metrics package
+
+package mirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/BryanMwangi/pine/metrics"
+)
+
+// statusMismatches and bodyMismatches count shadow responses that differ
+// from the primary ones, exported through the metrics package
+var (
+	statusMismatches = metrics.NewCounter("mirror_status_mismatches")
+	bodyMismatches   = metrics.NewCounter("mirror_body_mismatches")
+)
+
+// compare diffs the shadow response against the primary snapshot and
+// moves the mismatch counters
+func compare(cfg Config, method, target string, primary *primaryResponse, shadowStatus int, shadowBody []byte) {
+	if primary.status != shadowStatus {
+		statusMismatches.Inc()
+		logger.RuntimeInfo("mirror: status mismatch for " + method + " " + target +
+			" (" + strconv.Itoa(primary.status) + " vs " + strconv.Itoa(shadowStatus) + ")")
+		return
+	}
+	if !bodiesMatch(primary.body, shadowBody, cfg.IgnoreFields) {
+		bodyMismatches.Inc()
+		logger.RuntimeInfo("mirror: body mismatch for " + method + " " + target)
+	}
+}
+
+// bodiesMatch reports whether the two bodies are equivalent. JSON bodies
+// are compared normalized with the ignored fields removed, anything else
+// is compared byte for byte
+func bodiesMatch(primary, shadow []byte, ignore []string) bool {
+	normalizedPrimary, primaryIsJSON := normalizeJSON(primary, ignore)
+	normalizedShadow, shadowIsJSON := normalizeJSON(shadow, ignore)
+	if primaryIsJSON != shadowIsJSON {
+		return false
+	}
+	return bytes.Equal(normalizedPrimary, normalizedShadow)
+}
+
+// normalizeJSON re-encodes a JSON body so that two equivalent payloads
+// become byte identical, maps marshal with sorted keys. The second return
+// reports whether the body was JSON at all
+func normalizeJSON(raw []byte, ignore []string) ([]byte, bool) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return raw, false
+	}
+	if obj, ok := v.(map[string]interface{}); ok {
+		for _, field := range ignore {
+			delete(obj, field)
+		}
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw, false
+	}
+	return out, true
+}
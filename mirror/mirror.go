@@ -0,0 +1,167 @@
+// Pine's request mirroring middleware
+// New duplicates a configurable share of incoming requests to a shadow
+// upstream so a new service version can be exercised with production
+// traffic before it takes any of it. The copy is sent asynchronously
+// through pine.Client and its response is discarded, the client only ever
+// sees the primary handler's answer
+//
+//	app.Use(mirror.New(mirror.Config{
+//		Upstream:   "http://app-next.internal",
+//		Percentage: 10,
+//	}))
+
+package mirror
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/metrics"
+)
+
+// mirrored counts requests copied to the shadow upstream and failures
+// counts copies that could not be delivered, exported through the
+// metrics package
+var (
+	mirrored = metrics.NewCounter("mirror_requests")
+	failures = metrics.NewCounter("mirror_failures")
+)
+
+type Config struct {
+	// Upstream is the base URL of the shadow service,
+	// Eg: "http://app-next.internal"
+	// The request path and query string are appended to it
+	Upstream string
+
+	// Percentage is the share of requests mirrored, 0 to 100
+	//
+	// Default: 100
+	Percentage int
+
+	// Timeout for each shadow request
+	//
+	// Default: 10 seconds
+	Timeout time.Duration
+
+	// Compare diffs the shadow response against the primary one and moves
+	// the mismatch counters, see diff.go. Status codes are compared
+	// directly, JSON bodies are compared normalized so key order does not
+	// matter
+	//
+	// Default: false
+	Compare bool
+
+	// IgnoreFields lists top level JSON fields excluded from the body
+	// comparison, Eg timestamps and request ids that can never match
+	//
+	// Default: []string{}
+	IgnoreFields []string
+}
+
+// New builds the mirroring middleware
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		Percentage:   100,
+		Timeout:      10 * time.Second,
+		IgnoreFields: []string{},
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		cfg.Upstream = userConfig.Upstream
+		if userConfig.Percentage > 0 && userConfig.Percentage <= 100 {
+			cfg.Percentage = userConfig.Percentage
+		}
+		if userConfig.Timeout > 0 {
+			cfg.Timeout = userConfig.Timeout
+		}
+		cfg.Compare = userConfig.Compare
+		if userConfig.IgnoreFields != nil {
+			cfg.IgnoreFields = userConfig.IgnoreFields
+		}
+	}
+	upstream := strings.TrimSuffix(cfg.Upstream, "/")
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			if rand.Intn(100) >= cfg.Percentage {
+				return next(c)
+			}
+
+			// the body is buffered so the primary handler and the shadow
+			// copy can both consume it
+			var body []byte
+			if c.Request.Body != nil {
+				body, _ = io.ReadAll(c.Request.Body)
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			target := upstream + c.Request.URL.RequestURI()
+			// the full header is cloned so repeated values such as
+			// multiple Cookie lines reach the shadow upstream intact
+			headers := c.Request.Header.Clone()
+			// lets the shadow upstream tell mirrored traffic apart from
+			// real traffic, Eg to skip side effects like emails
+			headers.Set("X-Mirrored", "true")
+			method := c.Method
+
+			// the primary handler runs first so its response can be
+			// snapshotted for comparison, the copy then goes out without
+			// holding the client up
+			err := next(c)
+
+			var primary *primaryResponse
+			if cfg.Compare {
+				primary = &primaryResponse{
+					status: c.Response.StatusCode(),
+					body:   append([]byte(nil), c.Response.Body()...),
+				}
+			}
+			go shadow(cfg, method, target, headers, body, primary)
+			return err
+		}
+	}
+}
+
+// primaryResponse is the snapshot of the primary handler's answer that the
+// shadow response is diffed against
+type primaryResponse struct {
+	status int
+	body   []byte
+}
+
+// shadow delivers the copied request to the shadow upstream. The response
+// is dropped unless comparison is on, in which case it is diffed against
+// the primary snapshot. Delivery failures only move a counter, shadow
+// traffic must never affect the primary request
+func shadow(cfg Config, method, target string, headers http.Header, body []byte, primary *primaryResponse) {
+	mirrored.Inc()
+
+	client := pine.NewClientWithTimeout(cfg.Timeout)
+	request := client.Request()
+	request.SetRequestURI(target).SetMethod(method)
+	request.Header = headers
+	if len(body) > 0 {
+		request.RawBody(body)
+	}
+
+	if err := client.SendRequest(); err != nil {
+		failures.Inc()
+		return
+	}
+	code, shadowBody, err := client.ReadResponse()
+	if err != nil {
+		failures.Inc()
+		return
+	}
+	if primary != nil {
+		compare(cfg, method, target, primary, code, shadowBody)
+	}
+}
@@ -0,0 +1,78 @@
+package pine
+
+import (
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// MultipartResponse builds a multipart response body part by part, useful
+// for APIs that return a document and its metadata in one round trip
+//
+//	m := c.NewMultipart("related")
+//	m.JSONPart(metadata)
+//	m.FilePart("report.pdf", "application/pdf", file)
+//	return m.Close()
+type MultipartResponse struct {
+	ctx    *Ctx
+	writer *multipart.Writer
+}
+
+// NewMultipart starts a multipart response. The subtype defaults to "mixed",
+// pass "related" or any other subtype to override it. The boundary is
+// generated and written into the Content-Type header for you
+func (c *Ctx) NewMultipart(subtype ...string) *MultipartResponse {
+	sub := "mixed"
+	if len(subtype) > 0 && subtype[0] != "" {
+		sub = subtype[0]
+	}
+
+	writer := multipart.NewWriter(c.Response)
+	c.Set("Content-Type", "multipart/"+sub+"; boundary="+writer.Boundary())
+	c.Response.flushStatus()
+
+	return &MultipartResponse{ctx: c, writer: writer}
+}
+
+// JSONPart appends a part with an application/json body encoded with the
+// configured JSON encoder
+func (m *MultipartResponse) JSONPart(v interface{}) error {
+	raw, err := m.ctx.Server.config.JSONEncoder(v)
+	if err != nil {
+		return err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "application/json")
+	part, err := m.writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(raw)
+	return err
+}
+
+// FilePart appends a binary part streamed from r with the given file name
+// and content type
+func (m *MultipartResponse) FilePart(name, contentType string, r io.Reader) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	part, err := m.writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, r)
+	return err
+}
+
+// Part appends a part with arbitrary headers and returns a writer for its
+// body, for anything JSONPart and FilePart do not cover
+func (m *MultipartResponse) Part(header textproto.MIMEHeader) (io.Writer, error) {
+	return m.writer.CreatePart(header)
+}
+
+// Close writes the trailing boundary. Always call this once all parts have
+// been appended, usually as the handler's return value
+func (m *MultipartResponse) Close() error {
+	return m.writer.Close()
+}
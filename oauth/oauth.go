@@ -0,0 +1,389 @@
+// Pine's implementation of an OAuth2/OIDC client
+// This gives your app single sign-on using the authorization-code flow
+// without pulling in an external framework. A provider is configured by its
+// OIDC discovery URL, the endpoints are fetched lazily on first use
+//
+// Wire it up like this:
+//
+//	client := oauth.New(oauth.Config{
+//		Provider: oauth.Provider{
+//			DiscoveryURL: "https://accounts.google.com/.well-known/openid-configuration",
+//			ClientID:     os.Getenv("OAUTH_CLIENT_ID"),
+//			ClientSecret: os.Getenv("OAUTH_CLIENT_SECRET"),
+//			RedirectURL:  "https://example.com/auth/callback",
+//		},
+//	})
+//	client.Register(app, "/auth")
+//	app.Get("/me", profileHandler, client.RequireAuth())
+
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/cache"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Provider describes the identity provider the client talks to
+type Provider struct {
+	// DiscoveryURL points to the provider's OIDC discovery document,
+	// Eg: https://accounts.google.com/.well-known/openid-configuration
+	DiscoveryURL string
+
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the absolute URL of the callback handler registered
+	// with the provider
+	RedirectURL string
+
+	// Scopes requested during login
+	//
+	// Default: ["openid", "profile", "email"]
+	Scopes []string
+}
+
+// endpoints holds what we read out of the discovery document
+type endpoints struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// Token is the provider's token response together with its absolute expiry
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+
+	Expiry time.Time `json:"-"`
+}
+
+// Expired reports whether the access token needs a refresh. A small margin
+// is kept so a token is not used right at its expiry
+func (t *Token) Expired() bool {
+	if t.Expiry.IsZero() {
+		return false
+	}
+	return time.Now().After(t.Expiry.Add(-30 * time.Second))
+}
+
+type Config struct {
+	// The identity provider to authenticate against
+	Provider Provider
+
+	// Defines the name of the session cookie
+	//
+	// Default: "pine_session"
+	CookieName string
+
+	// Defines how long a login session is kept
+	//
+	// Default: 24 hours
+	SessionTTL time.Duration
+
+	// Defines where the user is redirected after a successful login
+	//
+	// Default: "/"
+	LoginRedirect string
+
+	// Defines the handler called when an unauthenticated request hits a
+	// route guarded by RequireAuth
+	//
+	// Default: returns a 401 status code
+	Unauthorized pine.Handler
+
+	// HTTPClient used for token and discovery requests
+	//
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+
+	// this is used internally to store sessions and login state
+	store *cache.Cache
+}
+
+// Client drives the authorization-code flow. Create one with New
+type Client struct {
+	cfg Config
+
+	discoverOnce sync.Once
+	endpoints    endpoints
+	discoverErr  error
+}
+
+// LocalsKey is the locals key RequireAuth stores the session token under
+const LocalsKey = "oauth_token"
+
+// stateCookieSuffix is appended to the session cookie name to form the
+// short-lived cookie that ties the login state to the browser that
+// started the flow
+const stateCookieSuffix = "_state"
+
+// ErrEmptyAccessToken is returned by exchange when the provider answered
+// with a 2xx response that carries no access token
+var ErrEmptyAccessToken = errors.New("token response contains no access token")
+
+func defaultUnauthorized(c *pine.Ctx) error {
+	return c.SendStatus(http.StatusUnauthorized)
+}
+
+func New(config ...Config) *Client {
+	cfg := Config{
+		CookieName:    "pine_session",
+		SessionTTL:    24 * time.Hour,
+		LoginRedirect: "/",
+		Unauthorized:  defaultUnauthorized,
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		cfg.Provider = userConfig.Provider
+		if userConfig.CookieName != "" {
+			cfg.CookieName = userConfig.CookieName
+		}
+		if userConfig.SessionTTL > 0 {
+			cfg.SessionTTL = userConfig.SessionTTL
+		}
+		if userConfig.LoginRedirect != "" {
+			cfg.LoginRedirect = userConfig.LoginRedirect
+		}
+		if userConfig.Unauthorized != nil {
+			cfg.Unauthorized = userConfig.Unauthorized
+		}
+		if userConfig.HTTPClient != nil {
+			cfg.HTTPClient = userConfig.HTTPClient
+		}
+	}
+	if len(cfg.Provider.Scopes) == 0 {
+		cfg.Provider.Scopes = []string{"openid", "profile", "email"}
+	}
+	cfg.store = cache.New()
+
+	return &Client{cfg: cfg}
+}
+
+// Register wires the login and callback handlers under the given prefix,
+// Eg: Register(app, "/auth") serves GET /auth/login and GET /auth/callback
+func (cl *Client) Register(server *pine.Server, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	server.Get(prefix+"/login", cl.Login())
+	server.Get(prefix+"/callback", cl.Callback())
+}
+
+// discover fetches the provider's discovery document once
+func (cl *Client) discover() error {
+	cl.discoverOnce.Do(func() {
+		resp, err := cl.cfg.HTTPClient.Get(cl.cfg.Provider.DiscoveryURL)
+		if err != nil {
+			cl.discoverErr = err
+			return
+		}
+		defer resp.Body.Close()
+		cl.discoverErr = json.NewDecoder(resp.Body).Decode(&cl.endpoints)
+	})
+	if cl.discoverErr != nil {
+		logger.RuntimeError("oauth discovery failed: " + cl.discoverErr.Error())
+	}
+	return cl.discoverErr
+}
+
+// Login starts the authorization-code flow. A random state is stored and
+// the user is redirected to the provider's authorization endpoint
+func (cl *Client) Login() pine.Handler {
+	return func(c *pine.Ctx) error {
+		if err := cl.discover(); err != nil {
+			return c.SendStatus(http.StatusBadGateway)
+		}
+
+		state := randomToken()
+		cl.cfg.store.Set("state:"+state, true, 10*time.Minute)
+
+		// the same state is also kept in a short-lived cookie so the
+		// callback only completes in the browser that started the flow
+		http.SetCookie(c.Response, &http.Cookie{
+			Name:     cl.cfg.CookieName + stateCookieSuffix,
+			Value:    state,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   int((10 * time.Minute).Seconds()),
+		})
+
+		query := url.Values{}
+		query.Set("response_type", "code")
+		query.Set("client_id", cl.cfg.Provider.ClientID)
+		query.Set("redirect_uri", cl.cfg.Provider.RedirectURL)
+		query.Set("scope", strings.Join(cl.cfg.Provider.Scopes, " "))
+		query.Set("state", state)
+
+		return redirect(c, cl.endpoints.AuthorizationEndpoint+"?"+query.Encode())
+	}
+}
+
+// Callback completes the flow. The state is checked against what Login
+// stored, the code is exchanged for tokens and a session cookie is set
+func (cl *Client) Callback() pine.Handler {
+	return func(c *pine.Ctx) error {
+		if err := cl.discover(); err != nil {
+			return c.SendStatus(http.StatusBadGateway)
+		}
+
+		state := c.Query("state")
+		if state == "" || !cl.cfg.store.Exists("state:"+state) {
+			return c.SendStatus(http.StatusForbidden)
+		}
+
+		// the state must come back from the browser that minted it
+		stateCookie, err := c.Request.Cookie(cl.cfg.CookieName + stateCookieSuffix)
+		if err != nil || stateCookie.Value != state {
+			return c.SendStatus(http.StatusForbidden)
+		}
+
+		cl.cfg.store.Delete("state:" + state)
+		http.SetCookie(c.Response, &http.Cookie{
+			Name:     cl.cfg.CookieName + stateCookieSuffix,
+			Value:    "",
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   -1,
+		})
+
+		code := c.Query("code")
+		if code == "" {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+
+		token, err := cl.exchange(url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {code},
+			"redirect_uri": {cl.cfg.Provider.RedirectURL},
+		})
+		if err != nil {
+			logger.RuntimeError("oauth code exchange failed: " + err.Error())
+			return c.SendStatus(http.StatusBadGateway)
+		}
+
+		session := randomToken()
+		cl.cfg.store.Set("session:"+session, token, cl.cfg.SessionTTL)
+
+		http.SetCookie(c.Response, &http.Cookie{
+			Name:     cl.cfg.CookieName,
+			Value:    session,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   int(cl.cfg.SessionTTL.Seconds()),
+		})
+		return redirect(c, cl.cfg.LoginRedirect)
+	}
+}
+
+// RequireAuth guards routes behind a valid session. The session's token is
+// stored in the locals under LocalsKey and refreshed when it has expired
+func (cl *Client) RequireAuth() pine.Middleware {
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			cookie, err := c.Request.Cookie(cl.cfg.CookieName)
+			if err != nil {
+				return cl.cfg.Unauthorized(c)
+			}
+
+			token, ok := cl.cfg.store.Get("session:" + cookie.Value).(*Token)
+			if !ok {
+				return cl.cfg.Unauthorized(c)
+			}
+
+			if token.Expired() {
+				token, err = cl.Refresh(token)
+				if err != nil {
+					cl.cfg.store.Delete("session:" + cookie.Value)
+					return cl.cfg.Unauthorized(c)
+				}
+				cl.cfg.store.Set("session:"+cookie.Value, token, cl.cfg.SessionTTL)
+			}
+
+			c.Locals(LocalsKey, token)
+			return next(c)
+		}
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token
+func (cl *Client) Refresh(token *Token) (*Token, error) {
+	if err := cl.discover(); err != nil {
+		return nil, err
+	}
+	fresh, err := cl.exchange(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// providers may omit the refresh token on refresh, keep the old one
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = token.RefreshToken
+	}
+	return fresh, nil
+}
+
+// exchange posts to the token endpoint and decodes the token response
+func (cl *Client) exchange(form url.Values) (*Token, error) {
+	form.Set("client_id", cl.cfg.Provider.ClientID)
+	form.Set("client_secret", cl.cfg.Provider.ClientSecret)
+
+	resp, err := cl.cfg.HTTPClient.PostForm(cl.endpoints.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// providers report a bad code or refresh token with an error status,
+	// never treat that body as a token
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	token := new(Token)
+	if err := json.NewDecoder(resp.Body).Decode(token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, ErrEmptyAccessToken
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+func redirect(c *pine.Ctx, location string) error {
+	c.Response.Header().Set("Location", location)
+	return c.SendStatus(http.StatusFound)
+}
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -2,15 +2,24 @@ package pine
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/google/uuid"
 )
 
 type Ctx struct {
@@ -31,8 +40,12 @@ type responseWriterWrapper struct {
 	http.ResponseWriter
 	//status code
 	statusCode int
+	//status set through c.Status, written together with the first body write
+	pendingStatus int
 	//body of the response
 	body []byte
+	//when set, response writing mistakes are diagnosed loudly, see diagnose
+	debug bool
 }
 
 type Server struct {
@@ -46,6 +59,12 @@ type Server struct {
 	//shutting them down will be here
 	onShutdown []func()
 
+	//callbacks that run once the listener is bound, see OnListen
+	onListen []func(addr string)
+
+	//address of the bound listener, holds a string, see Addr
+	listenAddr atomic.Value
+
 	//logger for errors
 	errorLog *log.Logger
 
@@ -58,6 +77,16 @@ type Server struct {
 
 	//middleware stack
 	middleware []Middleware
+
+	//prefix scoped fallbacks that run before the global 404, see Fallback
+	fallbacks []fallback
+
+	//compiled route table swapped in by Build for lock-free matching,
+	//holds a *[][]*compiledRoute
+	compiled atomic.Value
+
+	//unique id of this server instance, generated on creation
+	instanceID string
 }
 
 // Config is a struct holding the server settings.
@@ -82,6 +111,15 @@ type Config struct {
 	// Default: 5 Seconds
 	WriteTimeout time.Duration
 
+	// Caps the deadline a client may request through the X-Request-Timeout
+	// header. When set, the header's budget is applied to the request
+	// context so handlers and their downstream calls stop early, and
+	// outgoing client calls carry the remaining budget along. Zero
+	// disables the header entirely
+	//
+	// Default: 0
+	RequestTimeoutCap time.Duration
+
 	// Closes incomming connections after sending the first response to client.
 	// This is useful when you want to close connections after a specific route
 	//
@@ -103,6 +141,18 @@ type Config struct {
 
 	JSONDecoder JSONUnmarshal
 
+	// This defines the protobuf encoder used by c.Protobuf. When left unset
+	// messages must implement their own Marshal method
+	//
+	// Default: nil
+	ProtoEncoder ProtoMarshal
+
+	// This defines the protobuf decoder used by c.BindProtobuf. When left
+	// unset messages must implement their own Unmarshal method
+	//
+	// Default: nil
+	ProtoDecoder ProtoUnmarshal
+
 	// RequestMethods provides customizibility for HTTP methods. You can add/remove methods as you wish.
 	//
 	// Optional. Default: DefaultMethods
@@ -128,8 +178,122 @@ type Config struct {
 	// Default: html
 	Engine string
 
+	// AppName is the name of your application reported by the version
+	// endpoint, see ServeVersion
+	//
+	// Default: ""
+	AppName string
+
+	// Version is the build time version of your application.
+	// Set this from your build pipeline, Eg: with -ldflags "-X main.version=..."
+	// and pass it here for release tracking
+	//
+	// Default: ""
+	Version string
+
 	// TLSConfig is the configuration for TLS.
 	TLSConfig TLSConfig
+
+	// SigningSecret is the secret used to sign and verify temporary URLs,
+	// see SignedURL and SignedURLMiddleware
+	//
+	// Default: ""
+	SigningSecret string
+
+	// Upload holds the validation hooks applied to file uploads saved with
+	// SaveFile and SaveFileTo
+	//
+	// Default: no validation
+	Upload UploadConfig
+
+	// Debug renders detailed HTML error pages with stack traces and request
+	// dumps when a handler returns an error or panics. Never enable this in
+	// production, without it errors are returned as sanitized messages
+	//
+	// Default: true when the PINE_ENV environment variable is "development"
+	Debug bool
+
+	// Messages overrides the strings the framework emits itself so that a
+	// non-English API does not leak English defaults. Empty fields keep the
+	// built-in messages
+	Messages Messages
+
+	// ConnLimit caps concurrent connections per client IP and in total at
+	// the listener, excess connections receive a canned 503
+	//
+	// Default: unlimited
+	ConnLimit ConnLimitConfig
+
+	// TransferRate aborts connections that send or consume data slower
+	// than the configured minimum rates, see TransferRateConfig
+	//
+	// Default: no enforcement
+	TransferRate TransferRateConfig
+
+	// ServerHeader is added as the Server header on every response, so
+	// the identity does not need to be set by hand in each handler. Leave
+	// it empty to send no Server header at all
+	//
+	// Default: ""
+	ServerHeader string
+
+	// SecureDefaults applies a safe security baseline to every response:
+	// nosniff content types, same origin framing, no referrer leakage and
+	// TRACE requests rejected. Use the helmet middleware when you need
+	// finer control, its headers override these
+	//
+	// Default: false
+	SecureDefaults bool
+
+	// NotFoundHandler runs when no route matches the request path so that
+	// unmatched requests can return branded JSON or HTML instead of the
+	// plain text default
+	//
+	// Default: plain text 404 using Messages.NotFound
+	NotFoundHandler Handler
+
+	// MethodNotAllowedHandler runs when the path matches a route but the
+	// method does not
+	//
+	// Default: plain text 405 using Messages.MethodNotAllowed
+	MethodNotAllowedHandler Handler
+}
+
+// Messages is the catalog of framework-emitted strings that can be
+// translated through Config.Messages
+type Messages struct {
+	// Body of 404 responses
+	//
+	// Default: "404 page not found"
+	NotFound string
+
+	// Body of 405 responses
+	//
+	// Default: "Method Not Allowed"
+	MethodNotAllowed string
+
+	// Body of 413 responses for requests that exceed BodyLimit
+	//
+	// Default: "Request Entity Too Large"
+	BodyTooLarge string
+
+	// Per field message used when validation fails during binding
+	//
+	// Default: "is required"
+	FieldRequired string
+
+	// Body returned for handler errors when Debug is off
+	//
+	// Default: "Internal Server Error"
+	InternalServerError string
+}
+
+// message falls back to the built-in string when no translation is set
+func message(custom, fallback string) string {
+	if custom != "" {
+		return custom
+	}
+	return fallback
 }
 
 // Route is a struct that holds all metadata for each registered handler.
@@ -140,8 +304,57 @@ type Route struct {
 	Method string `json:"method"`
 	// Original registered route path
 	Path string `json:"path"`
+	// Optional name of the route used for reverse routing, see URLFor
+	Name string `json:"name,omitempty"`
 	// Ctx handlers
 	Handlers []Handler `json:"-"`
+	// Arbitrary metadata attached to the route, see Meta
+	meta map[string]interface{}
+}
+
+// As names a route so that its path can be rebuilt with URLFor
+// Eg: app.Get("/users/:id", handler).As("user")
+func (r *Route) As(name string) *Route {
+	if r != nil {
+		r.Name = name
+	}
+	return r
+}
+
+// ScopesMetaKey is the route metadata key under which RequireScope stores
+// the scopes a route demands. The authz middleware enforces them
+const ScopesMetaKey = "authz:scopes"
+
+// RequireScope declares the scopes a caller must hold to use this route,
+// Eg: app.Post("/users", handler).RequireScope("users:write")
+//
+// Declaring scopes does nothing on its own, register the authz middleware
+// to enforce them
+func (r *Route) RequireScope(scopes ...string) *Route {
+	if r != nil {
+		r.Meta(ScopesMetaKey, scopes)
+	}
+	return r
+}
+
+// Meta attaches arbitrary metadata to a route. Middleware and generators can
+// read it back through c.Route() for cross-cutting features such as
+// per-route auth scopes or schema descriptions
+//
+// Works like c.Locals: pass a value to set it, omit it to read it back
+// Eg: app.Get("/users", handler).Meta("schema", userSchema)
+func (r *Route) Meta(key string, value ...interface{}) interface{} {
+	if r == nil {
+		return nil
+	}
+	if len(value) == 0 {
+		return r.meta[key]
+	}
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = value[0]
+	return value[0]
 }
 
 // cookie struct that defines the structure of a cookie
@@ -215,6 +428,64 @@ type TLSConfig struct {
 	ServeTLS bool
 	CertFile string
 	KeyFile  string
+
+	// ClientCAFile is a PEM file with the certificate authorities client
+	// certificates are verified against. Setting it turns on mutual TLS,
+	// handlers read the verified peer through c.TLSPeerCertificates
+	//
+	// Default: ""
+	ClientCAFile string
+
+	// ClientAuth is the client certificate policy,
+	// Eg: tls.RequireAndVerifyClientCert
+	// When ClientCAFile is set and this is left at its zero value the
+	// policy defaults to requiring and verifying a certificate
+	//
+	// Default: tls.NoClientCert
+	ClientAuth tls.ClientAuthType
+
+	// Certificates lists additional cert and key pairs served from the
+	// same listener, the right one is picked per connection by SNI so one
+	// server can carry several domains. CertFile and KeyFile may stay
+	// empty when this is set
+	//
+	// Default: []CertPair{}
+	Certificates []CertPair
+
+	// GetCertificate fully replaces certificate selection, useful for
+	// certificates that live outside the filesystem such as ACME managed
+	// ones. When set it wins over CertFile and Certificates
+	//
+	// Default: nil
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// MinVersion is the lowest TLS version the server accepts, TLS 1.2
+	// is the floor. Raise it with tls.VersionTLS13 when every client is
+	// modern, it cannot be lowered below 1.2
+	//
+	// Default: tls.VersionTLS12
+	MinVersion uint16
+
+	// OCSPFile is a DER encoded OCSP response to staple onto the primary
+	// certificate, the file is re-read every OCSPRefreshInterval so a
+	// cron job or sidecar can keep it fresh. Applies to CertFile and
+	// KeyFile only, see tls.go
+	//
+	// Default: ""
+	OCSPFile string
+
+	// OCSPRefreshInterval is how often the staple and the certificate
+	// are reloaded from disk
+	//
+	// Default: 1 hour
+	OCSPRefreshInterval time.Duration
+}
+
+// CertPair is one certificate and key on disk, see
+// TLSConfig.Certificates
+type CertPair struct {
+	CertFile string
+	KeyFile  string
 }
 
 type SameSite int
@@ -231,7 +502,6 @@ const (
 	DefaultBodyLimit = 5 * 1024 * 1024 //5MB
 	statusMessageMin = 100
 	statusMessageMax = 511
-	queueCapacity    = 100
 )
 
 // Acceptable methods
@@ -247,6 +517,31 @@ const (
 	methodUse     = "USE"
 )
 
+// WebDAV methods
+// these are not part of DefaultMethods, add them to Config.RequestMethods
+// if you want to register WebDAV routes
+const (
+	MethodPropfind  = "PROPFIND"
+	MethodProppatch = "PROPPATCH"
+	MethodMkcol     = "MKCOL"
+	MethodCopy      = "COPY"
+	MethodMove      = "MOVE"
+	MethodLock      = "LOCK"
+	MethodUnlock    = "UNLOCK"
+)
+
+// WebDAVMethods can be appended to DefaultMethods when configuring the server
+// Eg: pine.New(pine.Config{RequestMethods: append(pine.DefaultMethods, pine.WebDAVMethods...)})
+var WebDAVMethods = []string{
+	MethodPropfind,
+	MethodProppatch,
+	MethodMkcol,
+	MethodCopy,
+	MethodMove,
+	MethodLock,
+	MethodUnlock,
+}
+
 // Default TLS config
 var defaultTLSConfig = TLSConfig{
 	ServeTLS: false,
@@ -256,25 +551,16 @@ var defaultTLSConfig = TLSConfig{
 
 // int equivalent of the mothod
 // this is used to speed up route matching instead of trying to match strings
+//
+// The index is looked up in the configured RequestMethods so that custom
+// methods such as the WebDAV methods can also be registered
 func (server *Server) methodInt(s string) int {
-	switch s {
-	case MethodGet:
-		return 0
-	case MethodHead:
-		return 1
-	case MethodPost:
-		return 2
-	case MethodPut:
-		return 3
-	case MethodDelete:
-		return 4
-	case MethodPatch:
-		return 5
-	case MethodOptions:
-		return 6
-	default:
-		return -1
+	for i, method := range server.config.RequestMethods {
+		if method == s {
+			return i
+		}
 	}
+	return -1
 }
 
 // Default methods, more coming soon
@@ -302,6 +588,7 @@ func New(config ...Config) *Server {
 		RequestMethods:   DefaultMethods,
 		TLSConfig:        defaultTLSConfig,
 		UploadPath:       "./uploads/",
+		Debug:            os.Getenv("PINE_ENV") == "development",
 	}
 
 	if len(config) > 0 {
@@ -317,6 +604,9 @@ func New(config ...Config) *Server {
 		if userConfig.WriteTimeout != 0 {
 			cfg.WriteTimeout = userConfig.WriteTimeout
 		}
+		if userConfig.RequestTimeoutCap != 0 {
+			cfg.RequestTimeoutCap = userConfig.RequestTimeoutCap
+		}
 		if userConfig.DisableKeepAlive {
 			cfg.DisableKeepAlive = userConfig.DisableKeepAlive
 		}
@@ -326,6 +616,12 @@ func New(config ...Config) *Server {
 		if userConfig.JSONDecoder != nil {
 			cfg.JSONDecoder = userConfig.JSONDecoder
 		}
+		if userConfig.ProtoEncoder != nil {
+			cfg.ProtoEncoder = userConfig.ProtoEncoder
+		}
+		if userConfig.ProtoDecoder != nil {
+			cfg.ProtoDecoder = userConfig.ProtoDecoder
+		}
 		if userConfig.RequestMethods != nil {
 			cfg.RequestMethods = userConfig.RequestMethods
 		}
@@ -338,12 +634,45 @@ func New(config ...Config) *Server {
 		if userConfig.UploadPath != "" {
 			cfg.UploadPath = userConfig.UploadPath
 		}
+		if userConfig.AppName != "" {
+			cfg.AppName = userConfig.AppName
+		}
+		if userConfig.Version != "" {
+			cfg.Version = userConfig.Version
+		}
+		if userConfig.Debug {
+			cfg.Debug = userConfig.Debug
+		}
+		// the zero value means no validation so copying it is safe
+		cfg.Upload = userConfig.Upload
+		if userConfig.SigningSecret != "" {
+			cfg.SigningSecret = userConfig.SigningSecret
+		}
+		// empty fields fall back to the built-in strings at use time
+		cfg.Messages = userConfig.Messages
+		// the zero value means unlimited so copying it is safe
+		cfg.ConnLimit = userConfig.ConnLimit
+		// the zero value means no enforcement so copying it is safe
+		cfg.TransferRate = userConfig.TransferRate
+		if userConfig.NotFoundHandler != nil {
+			cfg.NotFoundHandler = userConfig.NotFoundHandler
+		}
+		if userConfig.MethodNotAllowedHandler != nil {
+			cfg.MethodNotAllowedHandler = userConfig.MethodNotAllowedHandler
+		}
+		if userConfig.ServerHeader != "" {
+			cfg.ServerHeader = userConfig.ServerHeader
+		}
+		if userConfig.SecureDefaults {
+			cfg.SecureDefaults = userConfig.SecureDefaults
+		}
 	}
 
 	server := &Server{
-		config:   cfg,
-		stack:    make([][]*Route, len(cfg.RequestMethods)),
-		errorLog: log.New(os.Stderr, "ERROR: ", log.LstdFlags),
+		config:     cfg,
+		stack:      make([][]*Route, len(cfg.RequestMethods)),
+		errorLog:   log.New(os.Stderr, "ERROR: ", log.LstdFlags),
+		instanceID: uuid.New().String(),
 	}
 
 	return server
@@ -351,14 +680,16 @@ func New(config ...Config) *Server {
 
 // This method is called to register routes and their respective methods
 // it also accepts handlers in case you want to use specific middlewares for specific routes
-func (server *Server) AddRoute(method, path string, handlers ...Handler) {
+//
+// The registered route is returned so that it can be named with As
+func (server *Server) AddRoute(method, path string, handlers ...Handler) *Route {
 	server.mutex.Lock()
 	defer server.mutex.Unlock()
 
 	methodIndex := server.methodInt(method)
 	if methodIndex == -1 {
 		server.errorLog.Printf("Invalid HTTP method: %s", method)
-		return
+		return nil
 	}
 
 	route := &Route{
@@ -368,7 +699,50 @@ func (server *Server) AddRoute(method, path string, handlers ...Handler) {
 	}
 
 	server.applyMiddleware(route)
-	server.stack[methodIndex] = append(server.stack[methodIndex], route)
+	// the stack is copied rather than appended in place so routes can be
+	// registered while the server is running, in-flight requests keep
+	// matching against the table they started with
+	routes := make([]*Route, len(server.stack[methodIndex]), len(server.stack[methodIndex])+1)
+	copy(routes, server.stack[methodIndex])
+	server.stack[methodIndex] = append(routes, route)
+	// keep the compiled table in sync once Build has run
+	if server.compiled.Load() != nil {
+		server.rebuild()
+	}
+	return route
+}
+
+// RemoveRoute unregisters the route with the given method and path while
+// the server is running. The route table is swapped copy-on-write so
+// requests in flight are unaffected. It reports whether a route was
+// removed, useful for plugin systems and feature flags that disable
+// endpoints dynamically
+func (server *Server) RemoveRoute(method, path string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	methodIndex := server.methodInt(method)
+	if methodIndex == -1 {
+		return false
+	}
+
+	removed := false
+	routes := make([]*Route, 0, len(server.stack[methodIndex]))
+	for _, route := range server.stack[methodIndex] {
+		if route.Path == path {
+			removed = true
+			continue
+		}
+		routes = append(routes, route)
+	}
+	if !removed {
+		return false
+	}
+	server.stack[methodIndex] = routes
+	if server.compiled.Load() != nil {
+		server.rebuild()
+	}
+	return true
 }
 
 // this is called on start up so that the server knows how to match routes and methods
@@ -384,6 +758,27 @@ func matchRoute(routePath, requestPath string) (bool, map[string]string) {
 		routeSegments := splitPath(routePath)
 		requestSegments := splitPath(requestPath)
 
+		// A trailing "*" matches the rest of the request path no matter how
+		// deep it goes. The matched remainder is stored in params under "*"
+		// Eg: "/share/*" matches "/share/docs/notes.txt" with params["*"] = "docs/notes.txt"
+		last := len(routeSegments) - 1
+		if routeSegments[last] == "*" {
+			if len(requestSegments) < last {
+				return false, nil
+			}
+			params := make(map[string]string)
+			for i := 0; i < last; i++ {
+				segment := routeSegments[i]
+				if segment[0] == ':' {
+					params[segment[1:]] = requestSegments[i]
+				} else if segment != requestSegments[i] {
+					return false, nil
+				}
+			}
+			params["*"] = strings.Join(requestSegments[last:], "/")
+			return true, params
+		}
+
 		if len(routeSegments) == len(requestSegments) {
 			params := make(map[string]string)
 			for i, segment := range routeSegments {
@@ -405,31 +800,115 @@ func splitPath(path string) []string {
 	return strings.Split(strings.Trim(path, "/"), "/")
 }
 
-func (server *Server) Get(path string, handlers ...Handler) {
-	server.AddRoute(MethodGet, path, handlers...)
+func (server *Server) Get(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodGet, path, handlers...)
 }
-func (server *Server) Post(path string, handlers ...Handler) {
-	server.AddRoute(MethodPost, path, handlers...)
+func (server *Server) Post(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodPost, path, handlers...)
 }
-func (server *Server) Put(path string, handlers ...Handler) {
-	server.AddRoute(MethodPut, path, handlers...)
+func (server *Server) Put(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodPut, path, handlers...)
 }
-func (server *Server) Patch(path string, handlers ...Handler) {
-	server.AddRoute(MethodPatch, path, handlers...)
+func (server *Server) Patch(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodPatch, path, handlers...)
 }
-func (server *Server) Delete(path string, handlers ...Handler) {
-	server.AddRoute(MethodDelete, path, handlers...)
+func (server *Server) Delete(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodDelete, path, handlers...)
 }
 
-func (server *Server) Options(path string, handlers ...Handler) {
-	server.AddRoute(MethodOptions, path, handlers...)
+func (server *Server) Options(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodOptions, path, handlers...)
+}
+
+// WebDAV helpers. These only work if the corresponding method has been added
+// to Config.RequestMethods, see WebDAVMethods for a convenient way to do so
+func (server *Server) Propfind(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodPropfind, path, handlers...)
+}
+func (server *Server) Proppatch(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodProppatch, path, handlers...)
+}
+func (server *Server) Mkcol(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodMkcol, path, handlers...)
+}
+func (server *Server) Copy(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodCopy, path, handlers...)
+}
+func (server *Server) Move(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodMove, path, handlers...)
+}
+func (server *Server) Lock(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodLock, path, handlers...)
+}
+func (server *Server) Unlock(path string, handlers ...Handler) *Route {
+	return server.AddRoute(MethodUnlock, path, handlers...)
+}
+
+// Typed startup errors. Supervisors can match these with errors.Is to tell
+// a port that is already taken apart from a broken TLS configuration
+var (
+	// ErrBindFailed wraps listener errors such as a port already in use
+	ErrBindFailed = errors.New("pine: bind failed")
+
+	// ErrTLSMisconfigured is returned when TLS serving is requested without
+	// a usable certificate and key pair
+	ErrTLSMisconfigured = errors.New("pine: tls misconfigured")
+)
+
+// OnListen registers callbacks that run once the listener is bound, before
+// the server begins accepting connections. The bound address is passed in,
+// which is the only way to learn the chosen port when starting on ":0"
+func (server *Server) OnListen(hooks ...func(addr string)) {
+	server.onListen = append(server.onListen, hooks...)
+}
+
+// Addr returns the address the server is listening on, empty until Start has
+// bound the listener. When starting on ":0" this carries the chosen port
+func (server *Server) Addr() string {
+	if addr, ok := server.listenAddr.Load().(string); ok {
+		return addr
+	}
+	return ""
+}
+
+// Port returns the port the server is listening on, 0 until Start has bound
+// the listener
+func (server *Server) Port() int {
+	_, port, err := net.SplitHostPort(server.Addr())
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
 }
 
 // Called to start the server after creating a new server
 //
 // You can put this in a go routine to handle graceful shut downs
 // You can check out an example on https://github/BryanMwangi/pine/Examples/RunningInGoRoutine/main.go
+//
+// Starting on ":0" lets the kernel pick a free port, read it back through
+// Addr, Port or an OnListen hook. This is handy for parallel integration
+// tests and for embedding Pine in desktop applications where no fixed port
+// can be assumed
 func (server *Server) Start(address string) error {
+	// compile the route table so request matching is lock-free
+	server.Build()
+
+	//certfile and keyfile are needed to handle https connections, fail
+	//before binding so nothing is held when the config is unusable
+	var tlsConfig *tls.Config
+	if server.config.TLSConfig.ServeTLS {
+		if !server.config.TLSConfig.hasCertificates() {
+			return fmt.Errorf("%w: certfile and keyfile are required to serve https", ErrTLSMisconfigured)
+		}
+		var err error
+		tlsConfig, err = server.buildTLSConfig()
+		if err != nil {
+			return err
+		}
+	}
+
 	httpServer := &http.Server{
 		Addr:         address,
 		ReadTimeout:  server.config.ReadTimeout,
@@ -440,19 +919,96 @@ func (server *Server) Start(address string) error {
 	server.server = httpServer
 	server.server.SetKeepAlivesEnabled(!server.config.DisableKeepAlive)
 
-	//certfile and keyfile are needed to handle https connections
-	//if the certfile and keyfile are not empty strings the server panic
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBindFailed, err)
+	}
+
+	// expose the bound address and tell anyone waiting for it, with ":0"
+	// this is where the kernel's port choice becomes visible
+	server.listenAddr.Store(listener.Addr().String())
+	for _, hook := range server.onListen {
+		hook(listener.Addr().String())
+	}
+
+	// a small startup banner so operators can see what bound where
+	banner := "Pine"
+	if server.config.AppName != "" {
+		banner = server.config.AppName
+	}
+	if server.config.Version != "" {
+		banner += " v" + server.config.Version
+	}
+	logger.RuntimeInfo(banner + " listening on " + listener.Addr().String())
+	// connection limits are enforced at the listener so floods are cut off
+	// before request parsing
+	if server.config.ConnLimit.enabled() {
+		listener = newLimitedListener(listener, server.config.ConnLimit)
+	}
+	// slowloris protection wraps every accepted connection with minimum
+	// transfer rate tracking
+	if server.config.TransferRate.enabled() {
+		listener = newRateListener(listener, server.config.TransferRate)
+	}
+
 	if server.config.TLSConfig.ServeTLS {
-		if server.config.TLSConfig.CertFile == "" || server.config.TLSConfig.KeyFile == "" {
-			panic("certfile and keyfile are required to serve https")
-		}
-		return httpServer.ListenAndServeTLS(server.config.TLSConfig.CertFile, server.config.TLSConfig.KeyFile)
+		httpServer.TLSConfig = tlsConfig
+		return server.serveTLS(httpServer, listener)
+	}
+	return httpServer.Serve(listener)
+}
+
+// HeaderRequestTimeout carries a client's deadline budget, see
+// Config.RequestTimeoutCap
+const HeaderRequestTimeout = "X-Request-Timeout"
+
+// parseRequestTimeout reads a deadline budget header, Go duration strings
+// like "250ms" as well as bare integers meaning milliseconds are accepted
+func parseRequestTimeout(raw string) time.Duration {
+	if raw == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+		return d
+	}
+	if ms, err := strconv.Atoi(raw); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
 	}
-	return httpServer.ListenAndServe()
+	return 0
 }
 
 func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	wrappedWriter := &responseWriterWrapper{ResponseWriter: w}
+	wrappedWriter := &responseWriterWrapper{ResponseWriter: w, debug: server.config.Debug}
+
+	// honour a client supplied deadline budget, capped by the server config
+	if server.config.RequestTimeoutCap > 0 {
+		if budget := parseRequestTimeout(r.Header.Get(HeaderRequestTimeout)); budget > 0 {
+			if budget > server.config.RequestTimeoutCap {
+				budget = server.config.RequestTimeoutCap
+			}
+			timeoutCtx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+			r = r.WithContext(timeoutCtx)
+		}
+	}
+
+	// stamp the configured server identity on every response
+	if server.config.ServerHeader != "" {
+		wrappedWriter.Header().Set("Server", server.config.ServerHeader)
+	}
+
+	if server.config.SecureDefaults {
+		// TRACE echoes the request back and is a classic way to read
+		// headers that scripts cannot see, nobody needs it enabled
+		if r.Method == http.MethodTrace {
+			http.Error(w, message(server.config.Messages.MethodNotAllowed, "Method Not Allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+		header := wrappedWriter.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+		header.Set("Referrer-Policy", "no-referrer")
+	}
 
 	ctx := &Ctx{
 		Server:   server,
@@ -464,20 +1020,39 @@ func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var matchedRoute *Route
-	for _, routes := range server.stack {
-		for _, route := range routes {
-			if matched, params := matchRoute(route.Path, r.URL.Path); matched {
+
+	// Prefer routes registered for the request's own method so that the same
+	// path can be registered under multiple methods
+	// OPTIONS requests are excluded as they are handled by the CORS middleware
+	// of the matched route
+	// the request path is split once, compiled matchers reuse the segments
+	requestSegments := splitPath(r.URL.Path)
+	table := server.compiledTable()
+
+	methodIndex := server.methodInt(r.Method)
+	if methodIndex != -1 && r.Method != MethodOptions {
+		if route, params := server.matchIn(table, methodIndex, r.URL.Path, requestSegments); route != nil {
+			matchedRoute = route
+			ctx.params = params
+		}
+	}
+
+	// Fall back to matching the path on any method so that we can still
+	// answer preflight requests and respond with 405 on method mismatches
+	if matchedRoute == nil {
+		for idx := range server.stack {
+			if route, params := server.matchIn(table, idx, r.URL.Path, requestSegments); route != nil {
 				matchedRoute = route
 				ctx.params = params
 				break
 			}
 		}
-		if matchedRoute != nil {
-			break
-		}
 	}
 
 	if matchedRoute != nil {
+		// make the matched route available to handlers and middleware
+		ctx.route = matchedRoute
+
 		// for CORS we need to check if the method if OPTIONS and we pass the request
 		// to the first handler in the stack
 		// TODO: not just the first handler but all handlers except the last handler
@@ -487,31 +1062,99 @@ func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		server.limitMaxRequestBodySize(w, r)
+		if !server.limitMaxRequestBodySize(w, r) {
+			return
+		}
 
 		// Proceed to check if the method matches the method in the route
 		if matchedRoute.Method != r.Method {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			if server.config.MethodNotAllowedHandler != nil {
+				if err := server.runHandler(server.config.MethodNotAllowedHandler, ctx); err != nil {
+					server.handleError(ctx, err)
+				}
+				return
+			}
+			http.Error(w, message(server.config.Messages.MethodNotAllowed, "Method Not Allowed"), http.StatusMethodNotAllowed)
 			return
 		}
 
 		// Call the handlers for the matched route
 		for _, handler := range matchedRoute.Handlers {
-			err := handler(ctx)
+			err := server.runHandler(handler, ctx)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				server.handleError(ctx, err)
 				return
 			}
 		}
 		return
 	}
 
+	if handler := server.matchFallback(r.URL.Path); handler != nil {
+		if err := server.runHandler(handler, ctx); err != nil {
+			server.handleError(ctx, err)
+		}
+		return
+	}
+
+	if server.config.NotFoundHandler != nil {
+		if err := server.runHandler(server.config.NotFoundHandler, ctx); err != nil {
+			server.handleError(ctx, err)
+		}
+		return
+	}
+	if server.config.Messages.NotFound != "" {
+		http.Error(w, server.config.Messages.NotFound, http.StatusNotFound)
+		return
+	}
 	http.NotFound(w, r)
 }
 
-func (server *Server) limitMaxRequestBodySize(w http.ResponseWriter, r *http.Request) {
+// Forms are no longer parsed here, parsing happens lazily the first time a
+// form helper such as c.FormValue is used so that routes that never touch
+// the form do not pay for parsing it
+// limitMaxRequestBodySize caps the request body at BodyLimit. Requests that
+// declare a larger body are rejected up front, everything else is wrapped so
+// oversized chunked bodies fail once they are read
+func (server *Server) limitMaxRequestBodySize(w http.ResponseWriter, r *http.Request) bool {
+	if r.ContentLength > server.config.BodyLimit {
+		http.Error(w, message(server.config.Messages.BodyTooLarge, "Request Entity Too Large"), http.StatusRequestEntityTooLarge)
+		return false
+	}
 	r.Body = http.MaxBytesReader(w, r.Body, server.config.BodyLimit)
-	r.ParseMultipartForm(server.config.BodyLimit)
+	return true
+}
+
+// fallback pairs a path prefix with the handler that answers unmatched
+// requests under it
+type fallback struct {
+	prefix  string
+	handler Handler
+}
+
+// Fallback registers a handler that runs when no route matched a request
+// whose path starts with the given prefix, before the global 404. This is
+// useful to serve a SPA's index.html for client side routes under "/app"
+// while API paths still 404 properly
+//
+// When prefixes overlap the longest matching one wins
+func (server *Server) Fallback(prefix string, handler Handler) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.fallbacks = append(server.fallbacks, fallback{prefix: prefix, handler: handler})
+}
+
+// matchFallback returns the handler of the longest registered prefix the
+// path falls under, or nil when none match
+func (server *Server) matchFallback(path string) Handler {
+	var matched Handler
+	longest := -1
+	for _, f := range server.fallbacks {
+		if strings.HasPrefix(path, f.prefix) && len(f.prefix) > longest {
+			matched = f.handler
+			longest = len(f.prefix)
+		}
+	}
+	return matched
 }
 
 // Use method is for specifying middleware to be used on specific routes
@@ -545,6 +1188,49 @@ func (c *Ctx) Context() context.Context {
 	return c.Request.Context()
 }
 
+// Route returns the route matched for this request. Returns nil when no
+// route has been matched, Eg: in tests that build a Ctx by hand
+func (c *Ctx) Route() *Route {
+	return c.route
+}
+
+// FlagProvider answers feature flag checks for a request, the flags
+// subpackage ships providers and the middleware that installs one
+type FlagProvider interface {
+	Enabled(c *Ctx, name string) bool
+}
+
+// FlagsKey is the locals key the flags middleware stores its provider under
+const FlagsKey = "pine:flags"
+
+// FlagEnabled reports whether a feature flag is on for this request,
+// Eg: if c.FlagEnabled("new-checkout") { ... }
+//
+// Returns false when no flag middleware is registered
+func (c *Ctx) FlagEnabled(name string) bool {
+	provider, ok := c.Locals(FlagsKey).(FlagProvider)
+	if !ok {
+		return false
+	}
+	return provider.Enabled(c, name)
+}
+
+// ErrClientAborted is returned by response helpers when the client has
+// disconnected, long running handlers should treat it as a signal to stop
+var ErrClientAborted = errors.New("client aborted the request")
+
+// Done returns a channel that is closed when the client disconnects or the
+// request is otherwise cancelled. Select on it in long running handlers
+// such as exports or SSE streams to stop work early
+func (c *Ctx) Done() <-chan struct{} {
+	return c.Request.Context().Done()
+}
+
+// IsAborted reports whether the client has already disconnected
+func (c *Ctx) IsAborted() bool {
+	return c.Request.Context().Err() != nil
+}
+
 // Next is used to execute the next handler in the stack
 // This is useful when you want to execute the next handler in the stack
 // but you want to do some additional work before executing the next handler
@@ -685,6 +1371,13 @@ func (c *Ctx) Header(key string) string {
 	return c.Request.Header.Get(key)
 }
 
+// GetReqHeaders returns every request header with all of its values, for
+// the common case of one value use Header instead. The keys are in their
+// canonical form, Eg "Content-Type"
+func (c *Ctx) GetReqHeaders() map[string][]string {
+	return c.Request.Header
+}
+
 // Retrieves the IP address of the client
 //
 // If you notice the IP address is sometimes different from the actual IP address
@@ -764,27 +1457,84 @@ func (c *Ctx) Query(key string) string {
 // If you notice using c.Status(http.StatusOk).JSON(...json_payload) is not working
 // properly, you can simply use c.JSON(...json_payload) without specifying the status
 // Default status code is 200
+//
+// Sparse fieldsets are supported out of the box: when the request carries a
+// ?fields=a,b,c query only those top level fields are kept in the response.
+// This works for objects and for arrays of objects, useful for mobile
+// clients that want smaller payloads
 func (c *Ctx) JSON(data interface{}, status ...int) error {
+	if c.IsAborted() {
+		return ErrClientAborted
+	}
 	raw, err := c.Server.config.JSONEncoder(data)
 	if err != nil {
 		return err
 	}
+	if fields := c.Query("fields"); fields != "" {
+		raw = filterJSONFields(raw, fields)
+	}
 	c.Response.Header().Set("Content-Type", "application/json")
 	if len(status) > 0 {
 		c.Response.WriteHeader(status[0])
-	} else {
-		c.Response.WriteHeader(http.StatusOK)
 	}
 	c.Response.Write(raw)
 	return nil
 }
 
-// /You can use this to set the staus of a response
-// Eg: c.Status(http.StatusOk) or c.Status(200)
+// filterJSONFields keeps only the requested top level fields of an encoded
+// JSON object or array of objects. The raw bytes are returned untouched when
+// the payload is not shaped like that so that filtering can never break a
+// response
+func filterJSONFields(raw []byte, fields string) []byte {
+	selected := make(map[string]struct{})
+	for _, field := range strings.Split(fields, ",") {
+		selected[strings.TrimSpace(field)] = struct{}{}
+	}
+
+	filterObject := func(obj map[string]json.RawMessage) map[string]json.RawMessage {
+		out := make(map[string]json.RawMessage)
+		for field := range selected {
+			if value, ok := obj[field]; ok {
+				out[field] = value
+			}
+		}
+		return out
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		filtered, err := json.Marshal(filterObject(obj))
+		if err != nil {
+			return raw
+		}
+		return filtered
+	}
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		out := make([]map[string]json.RawMessage, len(list))
+		for i, obj := range list {
+			out[i] = filterObject(obj)
+		}
+		filtered, err := json.Marshal(out)
+		if err != nil {
+			return raw
+		}
+		return filtered
+	}
+	return raw
+}
+
+// You can use this to set the status of a response
+// Eg: c.Status(http.StatusOK) or c.Status(200)
+//
+// The status is not written immediately, it is sent together with the first
+// body write so it chains with the body helpers:
 //
-// Does not work well with c.JSON(...) as the response will be sent as plain text
+//	c.Status(201).JSON(user)
+//	c.Status(404).SendString("not found")
 func (c *Ctx) Status(status int) *Ctx {
-	c.Response.WriteHeader(status)
+	c.Response.pendingStatus = status
 	return c
 }
 
@@ -793,13 +1543,94 @@ func (c *Ctx) Set(key string, val interface{}) *Ctx {
 	return c
 }
 
-// SendString sends a string as the response
+// Append adds a value to a response header without overwriting the ones
+// already there, use it for headers that legitimately carry several
+// values such as Set-Cookie or Link. Set replaces, Append accumulates
+func (c *Ctx) Append(key string, val interface{}) *Ctx {
+	c.Response.Header().Add(key, fmt.Sprint(val))
+	return c
+}
+
+// Vary adds the given header names to the response's Vary header so
+// caches key on them, each name is only ever added once:
+//
+//	c.Vary("Accept", "Accept-Encoding")
+func (c *Ctx) Vary(fields ...string) *Ctx {
+	existing := c.Response.Header().Values("Vary")
+	for _, field := range fields {
+		canonical := http.CanonicalHeaderKey(field)
+		seen := false
+		for _, have := range existing {
+			for _, part := range strings.Split(have, ",") {
+				if http.CanonicalHeaderKey(strings.TrimSpace(part)) == canonical {
+					seen = true
+				}
+			}
+		}
+		if !seen {
+			c.Response.Header().Add("Vary", canonical)
+			existing = append(existing, canonical)
+		}
+	}
+	return c
+}
+
+// SendString sends a string as the response. A charset may be passed to
+// send it as text with an explicit encoding:
+//
+//	c.SendString("héllo", "utf-8")
+//
 // Default status code is 200
-func (c *Ctx) SendString(body string) error {
+func (c *Ctx) SendString(body string, charset ...string) error {
+	if c.IsAborted() {
+		return ErrClientAborted
+	}
+	if len(charset) > 0 {
+		c.Response.Header().Set("Content-Type", "text/plain; charset="+charset[0])
+	}
 	c.Response.Write([]byte(body))
 	return nil
 }
 
+// Send writes raw bytes as the response. When no Content-Type has been
+// set yet the type is sniffed from the first bytes of the body, set one
+// beforehand with Type to skip the sniffing
+// Default status code is 200
+func (c *Ctx) Send(body []byte) error {
+	if c.IsAborted() {
+		return ErrClientAborted
+	}
+	if c.Response.Header().Get("Content-Type") == "" {
+		c.Response.Header().Set("Content-Type", http.DetectContentType(body))
+	}
+	c.Response.Write(body)
+	return nil
+}
+
+// SendBytes is an alias of Send for readers coming from frameworks that
+// name it that way
+func (c *Ctx) SendBytes(body []byte) error {
+	return c.Send(body)
+}
+
+// Type sets the Content-Type of the response by file extension, with or
+// without the leading dot, so handlers do not need to remember full MIME
+// types:
+//
+//	c.Type("json").Send(raw)
+//	c.Type(".html")
+//
+// Unknown extensions leave the Content-Type untouched
+func (c *Ctx) Type(extension string) *Ctx {
+	if !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+	if mediaType := mime.TypeByExtension(extension); mediaType != "" {
+		c.Response.Header().Set("Content-Type", mediaType)
+	}
+	return c
+}
+
 // You can send just the status message here
 func StatusMessage(status int) string {
 	if status < statusMessageMin || status > statusMessageMax {
@@ -822,6 +1653,91 @@ func (c *Ctx) SendStatus(status int) error {
 	return nil
 }
 
+// FreshSince evaluates the conditional request headers If-None-Match and
+// If-Modified-Since against the given last modified time and entity tag.
+// The Last-Modified and ETag headers are set on the response either way
+//
+// When the client's cached copy is still fresh a 304 Not Modified is written
+// and true is returned so that handlers can short-circuit cheaply:
+//
+//	if c.FreshSince(updatedAt, etag) {
+//		return nil
+//	}
+//	return c.JSON(payload)
+//
+// Pass an empty etag or a zero time to skip the respective check
+func (c *Ctx) FreshSince(lastModified time.Time, etag string) bool {
+	if etag != "" {
+		c.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		c.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	fresh := false
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if match := c.Header("If-None-Match"); match != "" && etag != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || candidate == etag {
+				fresh = true
+				break
+			}
+		}
+	} else if since := c.Header("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		if parsed, err := http.ParseTime(since); err == nil {
+			// timestamps in headers only carry second precision
+			fresh = !lastModified.Truncate(time.Second).After(parsed)
+		}
+	}
+
+	if fresh {
+		c.Response.WriteHeader(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// error subscribers are shared across the package so background systems
+// without a server reference, the cron and websocket packages, can surface
+// their errors through the same channel
+var (
+	errorSubsMu sync.RWMutex
+	errorSubs   []func(err error, source string)
+)
+
+// OnError subscribes to every error the framework sees, handler errors as
+// well as failures in background jobs and websocket upgrades. source names
+// the subsystem that produced the error, "handler", "cron" or "websocket".
+// Use this to feed an error tracker without wrapping every handler:
+//
+//	app.OnError(func(err error, source string) {
+//		tracker.Capture(err, source)
+//	})
+func (server *Server) OnError(subscribers ...func(err error, source string)) {
+	errorSubsMu.Lock()
+	defer errorSubsMu.Unlock()
+	errorSubs = append(errorSubs, subscribers...)
+}
+
+// NotifyError fans an error out to every OnError subscriber, background
+// systems call this when something fails outside a request
+func NotifyError(err error, source string) {
+	errorSubsMu.RLock()
+	subscribers := errorSubs
+	errorSubsMu.RUnlock()
+	for _, subscriber := range subscribers {
+		subscriber(err, source)
+	}
+}
+
+// OnShutdown registers hooks that run when the server shuts down. Use this
+// to release resources such as database pools without threading them all
+// the way to the ServeShutDown call
+func (server *Server) OnShutdown(hooks ...func()) {
+	server.onShutdown = append(server.onShutdown, hooks...)
+}
+
 func (server *Server) ServeShutDown(ctx context.Context, hooks ...func()) error {
 	if server == nil {
 		return fmt.Errorf("shutdown: server is not running")
@@ -832,21 +1748,61 @@ func (server *Server) ServeShutDown(ctx context.Context, hooks ...func()) error
 		hook()
 	}
 
+	// buffered log lines would be lost once the process exits
+	logger.Flush()
+
 	return server.server.Shutdown(ctx)
 }
 
 func (rw *responseWriterWrapper) WriteHeader(statusCode int) {
-	if rw.statusCode == 0 {
-		rw.statusCode = statusCode
-		rw.ResponseWriter.WriteHeader(statusCode)
+	if rw.statusCode != 0 {
+		// the first status always wins, net/http would log a terse
+		// superfluous WriteHeader line, the diagnostic below names the
+		// call site instead
+		if len(rw.body) > 0 {
+			rw.diagnose(fmt.Sprintf("WriteHeader(%d) after the response body was started, status %d was already sent", statusCode, rw.statusCode))
+		} else {
+			rw.diagnose(fmt.Sprintf("WriteHeader(%d) called more than once, status %d was already sent", statusCode, rw.statusCode))
+		}
+		return
+	}
+	rw.statusCode = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// diagnose reports a response writing mistake such as writing the status
+// twice. In debug mode the message is logged together with the offending
+// call site so the handler at fault is easy to find, in production the
+// mistake is absorbed silently as before
+func (rw *responseWriterWrapper) diagnose(message string) {
+	if !rw.debug {
+		return
+	}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		message += " (called from " + file + ":" + strconv.Itoa(line) + ")"
 	}
+	logger.RuntimeError("pine: " + message)
 }
 
 func (rw *responseWriterWrapper) SetHeader(key, val string) {
 	rw.ResponseWriter.Header().Set(key, val)
 }
 
+// flushStatus writes the status deferred by c.Status, or 200 when none was
+// set, if no status has been written yet
+func (rw *responseWriterWrapper) flushStatus() {
+	if rw.statusCode != 0 {
+		return
+	}
+	if rw.pendingStatus != 0 {
+		rw.WriteHeader(rw.pendingStatus)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
 func (rw *responseWriterWrapper) Write(data []byte) (int, error) {
+	rw.flushStatus()
 	rw.body = append(rw.body, data...)
 	return rw.ResponseWriter.Write(data)
 }
@@ -854,3 +1810,15 @@ func (rw *responseWriterWrapper) Write(data []byte) (int, error) {
 func (rw *responseWriterWrapper) BodyLen() int {
 	return len(rw.body)
 }
+
+// StatusCode returns the status code written so far
+// Returns 0 if no status code has been written yet
+func (rw *responseWriterWrapper) StatusCode() int {
+	return rw.statusCode
+}
+
+// Body returns the response body written so far
+// This is useful for middlewares that need to inspect or replay responses
+func (rw *responseWriterWrapper) Body() []byte {
+	return rw.body
+}
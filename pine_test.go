@@ -1,9 +1,13 @@
 package pine
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,9 +18,7 @@ func Mock_Ctx() *Ctx {
 	}
 	ctx.Request = httptest.NewRequest(http.MethodGet, "/?query=queryValue", nil)
 	ctx.Response = &responseWriterWrapper{
-		httptest.NewRecorder(),
-		0,
-		nil,
+		ResponseWriter: httptest.NewRecorder(),
 	}
 	return &ctx
 }
@@ -551,3 +553,378 @@ func TestReadCookie(t *testing.T) {
 // TODO: Add tests involving responseWriterWrapper. As of now, such tests cannot
 // be verified as I have not figured out how to mock the responseWriterWrapper.
 // If you have any ideas, please feel free to share them.
+
+func TestJSON_FieldSelection(t *testing.T) {
+	server := New()
+	server.Get("/user", func(c *Ctx) error {
+		return c.JSON(map[string]interface{}{
+			"id":    42,
+			"name":  "pine",
+			"email": "pine@example.com",
+		})
+	})
+
+	req, err := http.NewRequest("GET", "/user?fields=id,name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `"id"`) || !strings.Contains(body, `"name"`) {
+		t.Errorf("expected id and name in response, got %s", body)
+	}
+	if strings.Contains(body, `"email"`) {
+		t.Errorf("expected email to be filtered out, got %s", body)
+	}
+}
+
+func TestFreshSince_ETagMatch(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("If-None-Match", `"v1"`)
+
+	if !ctx.FreshSince(time.Time{}, `"v1"`) {
+		t.Error("expected request to be fresh for a matching etag")
+	}
+	if ctx.Response.StatusCode() != http.StatusNotModified {
+		t.Errorf("expected status 304, got %v", ctx.Response.StatusCode())
+	}
+}
+
+func TestFreshSince_Stale(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("If-None-Match", `"v1"`)
+
+	if ctx.FreshSince(time.Time{}, `"v2"`) {
+		t.Error("expected request to be stale for a different etag")
+	}
+}
+
+func TestFreshSince_ModifiedSince(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour)
+
+	ctx := Mock_Ctx()
+	ctx.Request.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+
+	if !ctx.FreshSince(lastModified, "") {
+		t.Error("expected request to be fresh when not modified since")
+	}
+}
+
+func TestRemoveRoute(t *testing.T) {
+	server := New()
+	handler := func(c *Ctx) error { return nil }
+	server.Get("/dynamic", handler)
+
+	if !server.RemoveRoute("GET", "/dynamic") {
+		t.Fatal("expected RemoveRoute to report a removal")
+	}
+
+	methodIndex := server.methodInt("GET")
+	for _, route := range server.stack[methodIndex] {
+		if route.Path == "/dynamic" {
+			t.Error("expected route to be removed from the stack")
+		}
+	}
+
+	if server.RemoveRoute("GET", "/dynamic") {
+		t.Error("expected removing a missing route to report false")
+	}
+}
+
+func TestStatus_Chaining(t *testing.T) {
+	server := New()
+	server.Get("/created", func(c *Ctx) error {
+		return c.Status(http.StatusCreated).JSON(map[string]string{"ok": "yes"})
+	})
+	server.Get("/missing", func(c *Ctx) error {
+		return c.Status(http.StatusNotFound).SendString("not found")
+	})
+
+	req, _ := http.NewRequest("GET", "/created", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got: %v", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got: %v", rr.Code)
+	}
+	if rr.Body.String() != "not found" {
+		t.Errorf("expected body to be preserved, got: %v", rr.Body.String())
+	}
+}
+
+func TestSend_SniffsContentType(t *testing.T) {
+	ctx := Mock_Ctx()
+	if err := ctx.Send([]byte("<!DOCTYPE html><html></html>")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctx.Response.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Fatalf("expected a text/html content type, got '%s'", got)
+	}
+}
+
+func TestType_SetsContentTypeByExtension(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Type("json")
+	if got := ctx.Response.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Fatalf("expected an application/json content type, got '%s'", got)
+	}
+}
+
+func TestSendString_WithCharset(t *testing.T) {
+	ctx := Mock_Ctx()
+	if err := ctx.SendString("héllo", "utf-8"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctx.Response.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content type '%s'", got)
+	}
+}
+
+func TestStart_TLSMisconfigured(t *testing.T) {
+	server := New(Config{
+		TLSConfig: TLSConfig{ServeTLS: true},
+	})
+
+	err := server.Start(":0")
+	if err == nil {
+		t.Fatal("expected an error when serving TLS without certificates")
+	}
+	if !errors.Is(err, ErrTLSMisconfigured) {
+		t.Errorf("expected ErrTLSMisconfigured, got: %v", err)
+	}
+}
+
+func TestStart_EphemeralPort(t *testing.T) {
+	server := New()
+	server.Get("/ping", func(c *Ctx) error {
+		return c.SendString("pong")
+	})
+
+	bound := make(chan string, 1)
+	server.OnListen(func(addr string) {
+		bound <- addr
+	})
+
+	go server.Start(":0")
+
+	var addr string
+	select {
+	case addr = <-bound:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not bind within 2 seconds")
+	}
+
+	if server.Addr() != addr {
+		t.Errorf("expected Addr %q to match the OnListen address %q", server.Addr(), addr)
+	}
+	if server.Port() == 0 {
+		t.Error("expected Port to report the chosen port")
+	}
+
+	resp, err := http.Get("http://127.0.0.1:" + strconv.Itoa(server.Port()) + "/ping")
+	if err != nil {
+		t.Fatalf("failed to reach the server on its chosen port: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got: %v", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	server.ServeShutDown(ctx)
+}
+
+type testUsersController struct{}
+
+func (u *testUsersController) Index(c *Ctx) error {
+	return c.SendString("index")
+}
+
+func (u *testUsersController) Show(c *Ctx) error {
+	return c.SendString("user " + c.Params("id"))
+}
+
+func (u *testUsersController) Create(c *Ctx) error {
+	return c.SendString("created")
+}
+
+func (u *testUsersController) Delete(c *Ctx) error {
+	return nil
+}
+
+func TestResource(t *testing.T) {
+	server := New()
+	Resource(server, "/users", &testUsersController{})
+
+	cases := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{"GET", "/users", http.StatusOK, "index"},
+		{"GET", "/users/42", http.StatusOK, "user 42"},
+		{"POST", "/users", http.StatusCreated, "created"},
+		{"DELETE", "/users/42", http.StatusNoContent, ""},
+		// Update is not implemented so the route must not exist
+		{"PUT", "/users/42", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tc := range cases {
+		req, err := http.NewRequest(tc.method, tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		if rr.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, rr.Code)
+		}
+		if tc.body != "" && rr.Body.String() != tc.body {
+			t.Errorf("%s %s: expected body %q, got %q", tc.method, tc.path, tc.body, rr.Body.String())
+		}
+	}
+}
+
+func TestRequestTimeoutBudget(t *testing.T) {
+	server := New(Config{RequestTimeoutCap: time.Second})
+
+	var deadline time.Time
+	var hasDeadline bool
+	server.Get("/slow", func(c *Ctx) error {
+		deadline, hasDeadline = c.Context().Deadline()
+		return c.SendString("done")
+	})
+
+	req, err := http.NewRequest("GET", "/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(HeaderRequestTimeout, "10s")
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if !hasDeadline {
+		t.Fatal("expected the request context to carry a deadline")
+	}
+	if time.Until(deadline) > time.Second {
+		t.Error("expected the budget to be capped by RequestTimeoutCap")
+	}
+}
+
+func TestAppend_AccumulatesValues(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Append("Link", "</a>; rel=\"next\"")
+	ctx.Append("Link", "</b>; rel=\"prev\"")
+
+	if got := len(ctx.Response.Header().Values("Link")); got != 2 {
+		t.Fatalf("expected 2 Link values, got %d", got)
+	}
+}
+
+func TestVary_Deduplicates(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Vary("Accept", "accept-encoding")
+	ctx.Vary("accept")
+
+	values := ctx.Response.Header().Values("Vary")
+	if len(values) != 2 {
+		t.Fatalf("expected 2 Vary values, got %v", values)
+	}
+	if values[0] != "Accept" || values[1] != "Accept-Encoding" {
+		t.Fatalf("unexpected Vary values: %v", values)
+	}
+}
+
+func TestWriteHeader_FirstStatusWins(t *testing.T) {
+	ctx := Mock_Ctx()
+	ctx.Response.WriteHeader(http.StatusCreated)
+	ctx.Response.WriteHeader(http.StatusInternalServerError)
+
+	if ctx.Response.StatusCode() != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, ctx.Response.StatusCode())
+	}
+	recorder := ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected recorder status %d, got %d", http.StatusCreated, recorder.Code)
+	}
+}
+
+func TestGroup_MiddlewarePerGroup(t *testing.T) {
+	server := New()
+
+	policy := func(value string) Middleware {
+		return func(next Handler) Handler {
+			return func(c *Ctx) error {
+				c.Set("X-Origin-Policy", value)
+				if c.Method == MethodOptions {
+					return c.SendStatus(http.StatusNoContent)
+				}
+				return next(c)
+			}
+		}
+	}
+
+	widget := server.Group("/widget", policy("*"))
+	widget.Get("/embed", func(c *Ctx) error { return c.SendString("embed") })
+
+	admin := server.Group("/admin", policy("console"))
+	admin.Get("/stats", func(c *Ctx) error { return c.SendString("stats") })
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/widget/embed", nil)
+	server.ServeHTTP(rr, req)
+	if rr.Header().Get("X-Origin-Policy") != "*" {
+		t.Errorf("expected widget policy '*', got '%s'", rr.Header().Get("X-Origin-Policy"))
+	}
+	if rr.Body.String() != "embed" {
+		t.Errorf("expected body 'embed', got '%s'", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/stats", nil)
+	server.ServeHTTP(rr, req)
+	if rr.Header().Get("X-Origin-Policy") != "console" {
+		t.Errorf("expected admin policy 'console', got '%s'", rr.Header().Get("X-Origin-Policy"))
+	}
+}
+
+func TestGroup_AutomaticPreflight(t *testing.T) {
+	server := New()
+
+	cors := func(next Handler) Handler {
+		return func(c *Ctx) error {
+			c.Set("Access-Control-Allow-Origin", "https://console.example.com")
+			if c.Method == MethodOptions {
+				return c.SendStatus(http.StatusNoContent)
+			}
+			return next(c)
+		}
+	}
+
+	admin := server.Group("/admin", cors)
+	admin.Get("/stats", func(c *Ctx) error { return c.SendString("stats") })
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/admin/stats", nil)
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://console.example.com" {
+		t.Errorf("expected the preflight to carry the group's CORS headers")
+	}
+}
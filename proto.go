@@ -0,0 +1,86 @@
+package pine
+
+import (
+	"errors"
+	"io"
+)
+
+// ProtoMarshal and ProtoUnmarshal define the protobuf codec used by
+// c.Protobuf and c.BindProtobuf. Plug in your generated code's runtime here,
+// Eg: with google.golang.org/protobuf:
+//
+//	pine.New(pine.Config{
+//		ProtoEncoder: func(v interface{}) ([]byte, error) {
+//			return proto.Marshal(v.(proto.Message))
+//		},
+//		ProtoDecoder: func(data []byte, v interface{}) error {
+//			return proto.Unmarshal(data, v.(proto.Message))
+//		},
+//	})
+type ProtoMarshal func(v interface{}) ([]byte, error)
+
+type ProtoUnmarshal func(data []byte, v interface{}) error
+
+var (
+	ErrNoProtoCodec = errors.New("no protobuf codec configured and message does not implement Marshal/Unmarshal")
+)
+
+// selfMarshaler and selfUnmarshaler are implemented by gogo style generated
+// messages, used as a fallback when no codec is configured
+type selfMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type selfUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+// Protobuf writes a message as an application/x-protobuf response
+// If you would like to set the status code of the response, you can pass it
+// as the second argument the same way as with c.JSON
+//
+// Default status code is 200
+func (c *Ctx) Protobuf(msg interface{}, status ...int) error {
+	var raw []byte
+	var err error
+
+	switch {
+	case c.Server.config.ProtoEncoder != nil:
+		raw, err = c.Server.config.ProtoEncoder(msg)
+	default:
+		m, ok := msg.(selfMarshaler)
+		if !ok {
+			return ErrNoProtoCodec
+		}
+		raw, err = m.Marshal()
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Response.Header().Set("Content-Type", "application/x-protobuf")
+	if len(status) > 0 {
+		c.Response.WriteHeader(status[0])
+	}
+	c.Response.Write(raw)
+	return nil
+}
+
+// BindProtobuf decodes an application/x-protobuf request body into msg.
+// Like BindJSON this can be used to validate the body without further
+// handler logic
+func (c *Ctx) BindProtobuf(msg interface{}) error {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ErrParse
+	}
+
+	if c.Server != nil && c.Server.config.ProtoDecoder != nil {
+		return c.Server.config.ProtoDecoder(raw, msg)
+	}
+	m, ok := msg.(selfUnmarshaler)
+	if !ok {
+		return ErrNoProtoCodec
+	}
+	return m.Unmarshal(raw)
+}
@@ -0,0 +1,67 @@
+package pine
+
+import (
+	"github.com/BryanMwangi/pine/logger"
+	"github.com/google/uuid"
+)
+
+// RequestIDKey is the locals key the request id is stored under
+const RequestIDKey = "pine:request_id"
+
+// RequestID returns the correlation id of the request, taking an incoming
+// X-Request-ID header when the caller sent one and generating an id
+// otherwise. The id is cached in the locals and echoed on the response so
+// clients and upstream proxies can correlate their logs with yours
+func (c *Ctx) RequestID() string {
+	if id, ok := c.Locals(RequestIDKey).(string); ok && id != "" {
+		return id
+	}
+	id := c.Header("X-Request-ID")
+	if id == "" {
+		id = uuid.New().String()
+	}
+	c.Locals(RequestIDKey, id)
+	c.Set("X-Request-ID", id)
+	return id
+}
+
+// ReqLogger writes log lines tagged with a request's correlation fields,
+// create one with c.Logger
+type ReqLogger struct {
+	prefix string
+}
+
+// Logger returns a logger pre-tagged with the request id, method, path and
+// client IP so handler logs are always correlated without plumbing the
+// fields through by hand:
+//
+//	c.Logger().Info("payment captured")
+//
+// writes something like:
+//
+//	[d6f7...] GET /orders/42 10.0.0.7 - payment captured
+func (c *Ctx) Logger() *ReqLogger {
+	return &ReqLogger{
+		prefix: "[" + c.RequestID() + "] " + c.Method + " " + c.BaseURI + " " + c.IP() + " - ",
+	}
+}
+
+// Info logs an informational line with the request's tags
+func (l *ReqLogger) Info(message string) {
+	logger.Info(l.prefix + message)
+}
+
+// Error logs an error line with the request's tags
+func (l *ReqLogger) Error(message string) {
+	logger.Error(l.prefix + message)
+}
+
+// Warning logs a warning line with the request's tags
+func (l *ReqLogger) Warning(message string) {
+	logger.Warning(l.prefix + message)
+}
+
+// Success logs a success line with the request's tags
+func (l *ReqLogger) Success(message string) {
+	logger.Success(l.prefix + message)
+}
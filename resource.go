@@ -0,0 +1,77 @@
+package pine
+
+import "net/http"
+
+// The resource interfaces below are matched by Resource, a controller
+// implements whichever actions it supports and only those routes are
+// registered
+
+// ResourceIndexer lists the collection, mounted as GET path
+type ResourceIndexer interface {
+	Index(c *Ctx) error
+}
+
+// ResourceShower fetches one item, mounted as GET path/:id
+type ResourceShower interface {
+	Show(c *Ctx) error
+}
+
+// ResourceCreator creates an item, mounted as POST path and answering 201
+// unless the controller writes its own status
+type ResourceCreator interface {
+	Create(c *Ctx) error
+}
+
+// ResourceUpdater updates one item, mounted as PUT and PATCH path/:id
+type ResourceUpdater interface {
+	Update(c *Ctx) error
+}
+
+// ResourceDeleter removes one item, mounted as DELETE path/:id and
+// answering 204 when the controller writes nothing
+type ResourceDeleter interface {
+	Delete(c *Ctx) error
+}
+
+// Resource maps a controller's actions onto RESTful routes so CRUD services
+// do not repeat the same five registrations everywhere:
+//
+//	type Users struct{ store *UserStore }
+//
+//	func (u *Users) Index(c *pine.Ctx) error  { ... }
+//	func (u *Users) Show(c *pine.Ctx) error   { ... }
+//	func (u *Users) Create(c *pine.Ctx) error { ... }
+//
+//	pine.Resource(app, "/users", &Users{store})
+//
+// The item id is available as c.Params("id") in Show, Update and Delete
+func Resource(server *Server, path string, controller interface{}) {
+	if ctrl, ok := controller.(ResourceIndexer); ok {
+		server.Get(path, ctrl.Index)
+	}
+	if ctrl, ok := controller.(ResourceShower); ok {
+		server.Get(path+"/:id", ctrl.Show)
+	}
+	if ctrl, ok := controller.(ResourceCreator); ok {
+		server.Post(path, func(c *Ctx) error {
+			// a created resource answers 201, the deferred status lets
+			// the controller override it
+			c.Status(http.StatusCreated)
+			return ctrl.Create(c)
+		})
+	}
+	if ctrl, ok := controller.(ResourceUpdater); ok {
+		server.Put(path+"/:id", ctrl.Update)
+		server.Patch(path+"/:id", ctrl.Update)
+	}
+	if ctrl, ok := controller.(ResourceDeleter); ok {
+		server.Delete(path+"/:id", func(c *Ctx) error {
+			err := ctrl.Delete(c)
+			// a silent successful delete answers 204
+			if err == nil && c.Response.StatusCode() == 0 && c.Response.BodyLen() == 0 {
+				c.Response.WriteHeader(http.StatusNoContent)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package pine
+
+import (
+	"strings"
+)
+
+// compiledRoute is the precomputed matcher Build produces for one route.
+// The registered path is split and inspected once at build time so request
+// matching only compares segments and fills the parameter table, instead
+// of re-parsing the raw path string on every request
+type compiledRoute struct {
+	route *Route
+
+	// exact registered path for the fast equality check
+	path string
+
+	// the registered path split into segments
+	segments []string
+
+	// paramNames[i] holds the parameter name when segment i is dynamic
+	// and is empty for literal segments
+	paramNames []string
+
+	// whether the route ends in a "*" catch-all segment
+	catchAll bool
+}
+
+// compileRoute precomputes the matcher for a route
+func compileRoute(route *Route) *compiledRoute {
+	segments := splitPath(route.Path)
+	cr := &compiledRoute{
+		route:      route,
+		path:       route.Path,
+		segments:   segments,
+		paramNames: make([]string, len(segments)),
+	}
+	for i, segment := range segments {
+		if len(segment) > 0 && segment[0] == ':' {
+			cr.paramNames[i] = segment[1:]
+		}
+	}
+	if len(segments) > 0 && segments[len(segments)-1] == "*" {
+		cr.catchAll = true
+	}
+	return cr
+}
+
+// match mirrors matchRoute against pre-split request segments
+func (cr *compiledRoute) match(requestPath string, requestSegments []string) (bool, map[string]string) {
+	if cr.path == requestPath {
+		return true, make(map[string]string)
+	}
+	if len(cr.path) == 0 || cr.path[0] != '/' || len(requestPath) == 0 || requestPath[0] != '/' {
+		return false, nil
+	}
+
+	if cr.catchAll {
+		last := len(cr.segments) - 1
+		if len(requestSegments) < last {
+			return false, nil
+		}
+		params := make(map[string]string)
+		for i := 0; i < last; i++ {
+			if name := cr.paramNames[i]; name != "" {
+				params[name] = requestSegments[i]
+			} else if cr.segments[i] != requestSegments[i] {
+				return false, nil
+			}
+		}
+		params["*"] = strings.Join(requestSegments[last:], "/")
+		return true, params
+	}
+
+	if len(cr.segments) != len(requestSegments) {
+		return false, nil
+	}
+	params := make(map[string]string)
+	for i, segment := range cr.segments {
+		if name := cr.paramNames[i]; name != "" {
+			params[name] = requestSegments[i]
+		} else if segment != requestSegments[i] {
+			return false, nil
+		}
+	}
+	return true, params
+}
+
+// Build compiles the registered routes into precomputed matchers. Start
+// invokes this automatically, the compiled table is swapped in atomically
+// so request matching reads it without taking the server lock
+func (server *Server) Build() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.rebuild()
+}
+
+// rebuild recompiles the route table, callers must hold the server mutex
+func (server *Server) rebuild() {
+	table := make([][]*compiledRoute, len(server.stack))
+	for idx, routes := range server.stack {
+		compiled := make([]*compiledRoute, len(routes))
+		for i, route := range routes {
+			compiled[i] = compileRoute(route)
+		}
+		table[idx] = compiled
+	}
+	server.compiled.Store(&table)
+}
+
+// compiledTable returns the compiled route table, nil before Build ran
+func (server *Server) compiledTable() [][]*compiledRoute {
+	if v := server.compiled.Load(); v != nil {
+		return *v.(*[][]*compiledRoute)
+	}
+	return nil
+}
+
+// matchIn finds the first route in the given method stack matching the
+// path, preferring the compiled table when one has been built
+func (server *Server) matchIn(table [][]*compiledRoute, idx int, path string, segments []string) (*Route, map[string]string) {
+	if table != nil {
+		if idx >= len(table) {
+			return nil, nil
+		}
+		for _, cr := range table[idx] {
+			if matched, params := cr.match(path, segments); matched {
+				return cr.route, params
+			}
+		}
+		return nil, nil
+	}
+	for _, route := range server.stack[idx] {
+		if matched, params := matchRoute(route.Path, path); matched {
+			return route, params
+		}
+	}
+	return nil, nil
+}
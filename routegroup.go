@@ -0,0 +1,134 @@
+// Route groups
+// Group scopes a path prefix together with its own middleware, so one
+// part of the API can carry different cross-cutting behaviour than the
+// rest. The motivating case is route-specific CORS, public widget
+// endpoints that allow every origin next to an admin API that only
+// trusts the console:
+//
+//	widget := app.Group("/widget", cors.New(cors.Config{AllowedOrigins: []string{"*"}}))
+//	widget.Get("/embed", embedHandler)
+//
+//	admin := app.Group("/admin", cors.New(cors.Config{AllowedOrigins: []string{"https://console.example.com"}}))
+//	admin.Get("/stats", statsHandler)
+//
+// A group with middleware also answers OPTIONS on its routes through that
+// middleware, so CORS preflights work per route without registering
+// OPTIONS handlers by hand
+
+package pine
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Group registers routes under a shared prefix with shared middleware,
+// create one with Server.Group
+type Group struct {
+	server     *Server
+	prefix     string
+	middleware []Middleware
+
+	// bookkeeping for the automatic preflight routes, see AddRoute
+	mu          sync.Mutex
+	autoOptions map[string]bool
+	userOptions map[string]bool
+}
+
+// Group creates a route group. The prefix is prepended to every route
+// registered through it and the middleware wraps their handlers, routes
+// registered directly on the server are unaffected
+func (server *Server) Group(prefix string, middleware ...Middleware) *Group {
+	return &Group{
+		server:      server,
+		prefix:      strings.TrimSuffix(prefix, "/"),
+		middleware:  middleware,
+		autoOptions: make(map[string]bool),
+		userOptions: make(map[string]bool),
+	}
+}
+
+// Group nests a group inside this one, prefixes concatenate and the
+// parent's middleware runs before the child's
+func (g *Group) Group(prefix string, middleware ...Middleware) *Group {
+	combined := make([]Middleware, 0, len(g.middleware)+len(middleware))
+	combined = append(combined, g.middleware...)
+	combined = append(combined, middleware...)
+	return g.server.Group(g.prefix+prefix, combined...)
+}
+
+// Use appends middleware to the group, it only applies to routes
+// registered afterwards
+func (g *Group) Use(middleware ...Middleware) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// AddRoute registers a route under the group's prefix with the group's
+// middleware wrapped around its handlers
+//
+// For any other method than OPTIONS a preflight route answering OPTIONS
+// through the same middleware is registered alongside, CORS middleware in
+// the group therefore handles preflights per route. Registering an
+// OPTIONS route yourself replaces the automatic one
+func (g *Group) AddRoute(method, path string, handlers ...Handler) *Route {
+	fullPath := g.prefix + path
+
+	wrapped := make([]Handler, len(handlers))
+	for k, handler := range handlers {
+		wrapped[k] = g.wrap(handler)
+	}
+
+	if method == MethodOptions {
+		g.mu.Lock()
+		if g.autoOptions[fullPath] {
+			g.server.RemoveRoute(MethodOptions, fullPath)
+			delete(g.autoOptions, fullPath)
+		}
+		g.userOptions[fullPath] = true
+		g.mu.Unlock()
+		return g.server.AddRoute(method, fullPath, wrapped...)
+	}
+
+	route := g.server.AddRoute(method, fullPath, wrapped...)
+
+	// the automatic preflight route, its terminal handler only runs when
+	// no middleware answered the OPTIONS request itself
+	g.mu.Lock()
+	if len(g.middleware) > 0 && !g.autoOptions[fullPath] && !g.userOptions[fullPath] {
+		g.server.AddRoute(MethodOptions, fullPath, g.wrap(func(c *Ctx) error {
+			return c.SendStatus(http.StatusNoContent)
+		}))
+		g.autoOptions[fullPath] = true
+	}
+	g.mu.Unlock()
+	return route
+}
+
+// wrap applies the group's middleware to one handler, innermost last
+func (g *Group) wrap(handler Handler) Handler {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	return handler
+}
+
+func (g *Group) Get(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodGet, path, handlers...)
+}
+func (g *Group) Post(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodPost, path, handlers...)
+}
+func (g *Group) Put(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodPut, path, handlers...)
+}
+func (g *Group) Patch(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodPatch, path, handlers...)
+}
+func (g *Group) Delete(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodDelete, path, handlers...)
+}
+
+func (g *Group) Options(path string, handlers ...Handler) *Route {
+	return g.AddRoute(MethodOptions, path, handlers...)
+}
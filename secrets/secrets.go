@@ -0,0 +1,218 @@
+// Pine's secret provider abstraction
+// Secrets such as TLS keys, JWT secrets and cookie keys should not be
+// embedded in code. A Provider fetches them by name from wherever they
+// actually live and the rest of your app stays agnostic to the source,
+// Eg: loading the signing secret before building the server:
+//
+//	provider := secrets.Chain(
+//		secrets.Env("MYAPP_"),
+//		secrets.File("/run/secrets"),
+//	)
+//	app := pine.New(pine.Config{
+//		SigningSecret: secrets.MustGet(provider, "signing_secret"),
+//	})
+//
+// Wrap any provider with Cached to re-fetch on an interval so rotated
+// secrets are picked up without a restart
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BryanMwangi/pine/cache"
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// Provider fetches a secret by name
+type Provider interface {
+	Get(name string) (string, error)
+}
+
+// ErrNotFound is returned when a provider does not hold the secret
+var ErrNotFound = errors.New("secret not found")
+
+// MustGet fetches a secret and panics when it cannot be found, use it for
+// secrets the app cannot start without
+func MustGet(p Provider, name string) string {
+	value, err := p.Get(name)
+	if err != nil {
+		logger.RuntimeError("failed to load secret " + name + ": " + err.Error())
+		panic(err)
+	}
+	return value
+}
+
+// envProvider reads secrets from the environment
+type envProvider struct {
+	prefix string
+}
+
+// Env returns a provider backed by environment variables. The secret name
+// is upper-cased and prefixed, Eg: Env("MYAPP_").Get("jwt_secret") reads
+// MYAPP_JWT_SECRET
+func Env(prefix string) Provider {
+	return envProvider{prefix: prefix}
+}
+
+func (p envProvider) Get(name string) (string, error) {
+	value, ok := os.LookupEnv(p.prefix + strings.ToUpper(name))
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// fileProvider reads secrets from files in a directory
+type fileProvider struct {
+	dir string
+}
+
+// File returns a provider that reads each secret from a file named after
+// it inside dir. This matches how Docker and Kubernetes mount secrets,
+// Eg: File("/run/secrets").Get("tls_key") reads /run/secrets/tls_key
+func File(dir string) Provider {
+	return fileProvider{dir: dir}
+}
+
+func (p fileProvider) Get(name string) (string, error) {
+	// the name is cleaned so a secret name cannot escape the directory
+	raw, err := os.ReadFile(filepath.Join(p.dir, filepath.Base(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// VaultConfig configures the Vault provider
+type VaultConfig struct {
+	// Address of the Vault server, Eg: https://vault.internal:8200
+	Address string
+
+	// Token used to authenticate
+	Token string
+
+	// Mount of the KV v2 secrets engine
+	//
+	// Default: "secret"
+	Mount string
+
+	// Path under the mount the secrets live at, Eg: "myapp/production"
+	Path string
+
+	// HTTPClient used for requests
+	//
+	// Default: a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+type vaultProvider struct {
+	cfg VaultConfig
+}
+
+// Vault returns a provider backed by HashiCorp Vault's KV v2 engine. Each
+// Get reads the configured path and returns the field matching the secret
+// name
+func Vault(config VaultConfig) Provider {
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return vaultProvider{cfg: config}
+}
+
+func (p vaultProvider) Get(name string) (string, error) {
+	url := strings.TrimSuffix(p.cfg.Address, "/") + "/v1/" + p.cfg.Mount + "/data/" + p.cfg.Path
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.cfg.Token)
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("vault returned " + resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	value, ok := body.Data.Data[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// chainProvider tries providers in order
+type chainProvider struct {
+	providers []Provider
+}
+
+// Chain combines providers, each secret is fetched from the first provider
+// that holds it
+func Chain(providers ...Provider) Provider {
+	return chainProvider{providers: providers}
+}
+
+func (p chainProvider) Get(name string) (string, error) {
+	for _, provider := range p.providers {
+		value, err := provider.Get(name)
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", ErrNotFound
+}
+
+// cachedProvider remembers fetched secrets for a while
+type cachedProvider struct {
+	inner Provider
+	ttl   time.Duration
+	store *cache.Cache
+}
+
+// Cached wraps a provider so each secret is re-fetched after ttl. This
+// keeps remote providers like Vault off the hot path while still picking
+// up rotated secrets
+func Cached(inner Provider, ttl time.Duration) Provider {
+	return cachedProvider{inner: inner, ttl: ttl, store: cache.New()}
+}
+
+func (p cachedProvider) Get(name string) (string, error) {
+	if value, ok := p.store.Get(name).(string); ok {
+		return value, nil
+	}
+	value, err := p.inner.Get(name)
+	if err != nil {
+		return "", err
+	}
+	p.store.Set(name, value, p.ttl)
+	return value, nil
+}
@@ -0,0 +1,94 @@
+// systemd notification support
+// When a Pine app runs as a systemd unit with Type=notify, these helpers
+// report readiness and feed the unit's watchdog over the NOTIFY_SOCKET
+// protocol. Outside systemd every call is a harmless no-op, so the same
+// binary runs unchanged on a laptop, in a container or under an init system
+
+package service
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// ErrNoSocket is returned when the process was not started by a notify
+// aware init system, callers can usually ignore it
+var ErrNoSocket = errors.New("service: NOTIFY_SOCKET is not set")
+
+// Ready tells the init system that the app is up and serving, wire it into
+// an OnListen hook so readiness follows the bound listener:
+//
+//	app.OnListen(func(addr string) { service.Ready() })
+func Ready() error {
+	return notify("READY=1")
+}
+
+// Stopping tells the init system that a shutdown has begun, so restarts are
+// not counted as crashes
+func Stopping() error {
+	return notify("STOPPING=1")
+}
+
+// notify writes one state line to the NOTIFY_SOCKET datagram socket
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return ErrNoSocket
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval reports the watchdog interval the init system expects
+// pings within, false when no watchdog is configured for this service
+func WatchdogInterval() (time.Duration, bool) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Microsecond, true
+}
+
+// StartWatchdog begins pinging the init system's watchdog at half the
+// configured interval, but only while healthy reports true. Tie it to the
+// health subsystem so a wedged app is restarted by the init system:
+//
+//	service.StartWatchdog(deps.Ready)
+//
+// Pass nil to ping unconditionally. Returns false when no watchdog is
+// configured, in which case nothing runs
+func StartWatchdog(healthy func() bool) bool {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return false
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if healthy != nil && !healthy() {
+				// a missed ping is the signal, the init system will
+				// restart us once the interval elapses
+				logger.RuntimeError("watchdog ping withheld, app reports unhealthy")
+				continue
+			}
+			notify("WATCHDOG=1")
+		}
+	}()
+	return true
+}
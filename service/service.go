@@ -0,0 +1,88 @@
+// Pine's init system integration
+// Run wraps the usual Start call with everything an init system expects:
+// readiness is reported once the listener is bound, the watchdog is fed
+// while the app is healthy and SIGINT/SIGTERM trigger a graceful shutdown.
+// This covers systemd units with Type=notify as well as Windows services
+// run through a wrapper such as WinSW or NSSM that delivers console control
+// events, no platform specific code is needed in the app itself
+//
+//	func main() {
+//		app := pine.New()
+//		// routes...
+//		if err := service.Run(app, ":8080", service.Config{}); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+)
+
+type Config struct {
+	// ShutdownTimeout bounds the graceful shutdown after a stop signal
+	//
+	// Default: 30 seconds
+	ShutdownTimeout time.Duration
+
+	// Healthy gates the watchdog pings, return false to stop feeding the
+	// watchdog so the init system restarts the app. Use the dependencies
+	// registry's Ready method here
+	//
+	// Default: nil, pings are sent unconditionally
+	Healthy func() bool
+}
+
+// Run starts the server and blocks until it fails or a stop signal arrives,
+// then shuts it down gracefully. The error from Start or from the shutdown
+// is returned so supervisors see why the process exited
+func Run(server *pine.Server, address string, config ...Config) error {
+	cfg := Config{
+		ShutdownTimeout: 30 * time.Second,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.ShutdownTimeout > 0 {
+			cfg.ShutdownTimeout = userConfig.ShutdownTimeout
+		}
+		if userConfig.Healthy != nil {
+			cfg.Healthy = userConfig.Healthy
+		}
+	}
+
+	server.OnListen(func(addr string) {
+		Ready()
+		StartWatchdog(cfg.Healthy)
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Start(address)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	select {
+	case err := <-errs:
+		// the server stopped on its own, nothing left to shut down
+		return err
+	case <-stop:
+	}
+
+	Stopping()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	return server.ServeShutDown(ctx)
+}
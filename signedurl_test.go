@@ -0,0 +1,77 @@
+package pine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignedURL_RoundTrip(t *testing.T) {
+	server := New(Config{SigningSecret: "test-secret"})
+	server.Get("/files/:name", server.SignedURLMiddleware()(func(c *Ctx) error {
+		return c.SendString("file content")
+	}))
+
+	signed, err := server.SignedURL("/files/report.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to sign url: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, signed, nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 for a valid signature, got %v", rr.Code)
+	}
+}
+
+func TestSignedURL_Tampered(t *testing.T) {
+	server := New(Config{SigningSecret: "test-secret"})
+	server.Get("/files/:name", server.SignedURLMiddleware()(func(c *Ctx) error {
+		return c.SendString("file content")
+	}))
+
+	signed, err := server.SignedURL("/files/report.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to sign url: %v", err)
+	}
+
+	// request a different file with the same signature
+	tampered := "/files/secret.pdf" + signed[len("/files/report.pdf"):]
+	req := httptest.NewRequest(http.MethodGet, tampered, nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a tampered path, got %v", rr.Code)
+	}
+}
+
+func TestSignedURL_Expired(t *testing.T) {
+	server := New(Config{SigningSecret: "test-secret"})
+	server.Get("/files/:name", server.SignedURLMiddleware()(func(c *Ctx) error {
+		return c.SendString("file content")
+	}))
+
+	signed, err := server.SignedURL("/files/report.pdf", -time.Minute)
+	if err != nil {
+		t.Fatalf("failed to sign url: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, signed, nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for an expired link, got %v", rr.Code)
+	}
+}
+
+func TestSignedURL_NoSecret(t *testing.T) {
+	server := New()
+	if _, err := server.SignedURL("/files/report.pdf", time.Minute); err != ErrNoSigningSecret {
+		t.Errorf("expected ErrNoSigningSecret, got %v", err)
+	}
+}
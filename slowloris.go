@@ -0,0 +1,105 @@
+package pine
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// TransferRateConfig enforces minimum transfer rates per connection. The
+// coarse Read and Write timeouts only bound a whole request, a slowloris
+// style client can stay under them forever by trickling single bytes.
+// Connections that move data slower than these rates are aborted instead
+type TransferRateConfig struct {
+	// MinReadRate is the minimum bytes per second a client must send once
+	// the grace period has passed
+	//
+	// Default: 0 which disables the check
+	MinReadRate int64
+
+	// MinWriteRate is the minimum bytes per second a client must consume
+	// of the response once the grace period has passed
+	//
+	// Default: 0 which disables the check
+	MinWriteRate int64
+
+	// GracePeriod is how long a connection may live before the rates are
+	// enforced, so handshakes and think time are not punished
+	//
+	// Default: 10 seconds
+	GracePeriod time.Duration
+}
+
+// ErrTooSlow is the error a connection is closed with when it falls below
+// the configured transfer rates
+var ErrTooSlow = errors.New("connection below minimum transfer rate")
+
+// enabled reports whether any rate is configured
+func (cfg TransferRateConfig) enabled() bool {
+	return cfg.MinReadRate > 0 || cfg.MinWriteRate > 0
+}
+
+// rateListener wraps accepted connections with rate tracking
+type rateListener struct {
+	net.Listener
+	cfg TransferRateConfig
+}
+
+func newRateListener(inner net.Listener, cfg TransferRateConfig) *rateListener {
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = 10 * time.Second
+	}
+	return &rateListener{Listener: inner, cfg: cfg}
+}
+
+func (l *rateListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rateConn{Conn: conn, cfg: l.cfg, start: time.Now()}, nil
+}
+
+// rateConn aborts once the cumulative transfer rate in either direction
+// drops below the configured minimum
+type rateConn struct {
+	net.Conn
+	cfg     TransferRateConfig
+	start   time.Time
+	read    int64
+	written int64
+}
+
+func (c *rateConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read += int64(n)
+	if err == nil && c.tooSlow(c.read, c.cfg.MinReadRate) {
+		c.Close()
+		return n, ErrTooSlow
+	}
+	return n, err
+}
+
+func (c *rateConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written += int64(n)
+	if err == nil && c.tooSlow(c.written, c.cfg.MinWriteRate) {
+		c.Close()
+		return n, ErrTooSlow
+	}
+	return n, err
+}
+
+// tooSlow compares the bytes moved so far against what the minimum rate
+// demands for the connection's lifetime
+func (c *rateConn) tooSlow(bytes, min int64) bool {
+	if min <= 0 {
+		return false
+	}
+	elapsed := time.Since(c.start)
+	if elapsed <= c.cfg.GracePeriod {
+		return false
+	}
+	expected := min * int64(elapsed/time.Second)
+	return bytes < expected
+}
@@ -0,0 +1,107 @@
+package pine
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SPAConfig configures SPA, see its documentation for the defaults
+type SPAConfig struct {
+	// Index is the file served for client side routes, every path that
+	// does not resolve to a file in the filesystem falls back to it
+	//
+	// Default: "index.html"
+	Index string
+
+	// CacheImmutableAssets serves assets whose names carry a content hash,
+	// Eg: app.3f2d1c.js, with a long immutable Cache-Control header.
+	// The index and unhashed files are served with no-cache so new
+	// deployments are picked up immediately
+	//
+	// Default: false
+	CacheImmutableAssets bool
+}
+
+// hashedAsset matches file names that embed a content hash the way
+// bundlers emit them, Eg: app.3f2d1c8a.js or chunk-5a1b2c3d.css
+var hashedAsset = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[a-z0-9]+$`)
+
+// SPA serves a single page application from fsys under the given prefix.
+// Files that exist are served directly, anything else falls back to the
+// index so client side routing keeps working on hard refreshes,
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	app.SPA("/app", dist, pine.SPAConfig{CacheImmutableAssets: true})
+func (server *Server) SPA(prefix string, fsys fs.FS, config ...SPAConfig) {
+	cfg := SPAConfig{
+		Index: "index.html",
+	}
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Index != "" {
+			cfg.Index = userConfig.Index
+		}
+		if userConfig.CacheImmutableAssets {
+			cfg.CacheImmutableAssets = userConfig.CacheImmutableAssets
+		}
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	handler := func(c *Ctx) error {
+		rel := strings.TrimPrefix(c.BaseURI, prefix)
+		rel = strings.TrimPrefix(rel, "/")
+		// the path is cleaned so a request cannot escape the filesystem
+		rel = path.Clean(rel)
+		if rel == "." || rel == "/" {
+			rel = cfg.Index
+		}
+
+		name, fresh := rel, false
+		if !fileExists(fsys, name) {
+			// unknown paths are client side routes, serve the index
+			name = cfg.Index
+		} else if cfg.CacheImmutableAssets && hashedAsset.MatchString(name) {
+			fresh = true
+		}
+
+		file, err := fsys.Open(name)
+		if err != nil {
+			return c.SendStatus(http.StatusNotFound)
+		}
+		defer file.Close()
+
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			c.Set("Content-Type", contentType)
+		}
+		if fresh {
+			c.Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			c.Set("Cache-Control", "no-cache")
+		}
+
+		c.Response.flushStatus()
+		_, err = io.Copy(c.Response, file)
+		return err
+	}
+
+	server.Get(prefix+"/*", handler)
+	server.Get(prefix, handler)
+	// hard refreshes on client side routes must not hit the global 404
+	server.Fallback(prefix, handler)
+}
+
+// fileExists reports whether name resolves to a regular file in fsys
+func fileExists(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,131 @@
+// Pine's storage package defines a small object storage abstraction so that
+// file uploads can stream to local disk or an object store such as S3
+// without the handler caring which one is configured
+//
+// See c.SaveFileTo for streaming uploads straight into a Backend
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend is the interface every storage implementation satisfies
+type Backend interface {
+	// Put streams an object to the store under the given key
+	Put(key string, r io.Reader, size int64, contentType string) error
+
+	// Get returns a reader for the object stored under the given key.
+	// The caller is responsible for closing it
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes the object stored under the given key
+	Delete(key string) error
+
+	// SignedURL returns a URL for the object that expires after ttl
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+var (
+	ErrKeyRequired = errors.New("storage: key is required")
+)
+
+// Disk is a Backend that stores objects on the local filesystem
+type Disk struct {
+	// Root is the directory objects are stored under
+	//
+	// Default: "./uploads"
+	Root string
+
+	// BaseURL is prepended to keys when building signed URLs,
+	// Eg: "https://cdn.example.com/files"
+	BaseURL string
+
+	// Secret is the key used to sign URLs. SignedURL returns an error when
+	// it is empty
+	Secret string
+}
+
+// NewDisk returns a Disk backend rooted at the given directory
+func NewDisk(root string) *Disk {
+	if root == "" {
+		root = "./uploads"
+	}
+	return &Disk{Root: root}
+}
+
+func (d *Disk) path(key string) string {
+	// clean the key so that it cannot escape the root directory
+	key = filepath.Clean("/" + filepath.FromSlash(key))
+	return filepath.Join(d.Root, key)
+}
+
+func (d *Disk) Put(key string, r io.Reader, size int64, contentType string) error {
+	if key == "" {
+		return ErrKeyRequired
+	}
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, r)
+	return err
+}
+
+func (d *Disk) Get(key string) (io.ReadCloser, error) {
+	if key == "" {
+		return nil, ErrKeyRequired
+	}
+	return os.Open(d.path(key))
+}
+
+func (d *Disk) Delete(key string) error {
+	if key == "" {
+		return ErrKeyRequired
+	}
+	return os.Remove(d.path(key))
+}
+
+// SignedURL signs the key and expiry with the configured secret. The
+// signature can be verified with VerifySignedURL when serving the file
+func (d *Disk) SignedURL(key string, ttl time.Duration) (string, error) {
+	if key == "" {
+		return "", ErrKeyRequired
+	}
+	if d.Secret == "" {
+		return "", errors.New("storage: disk backend has no secret configured")
+	}
+	exp := time.Now().Add(ttl).Unix()
+	sig := signKey(d.Secret, key, exp)
+	return fmt.Sprintf("%s/%s?exp=%d&sig=%s", strings.TrimSuffix(d.BaseURL, "/"), key, exp, sig), nil
+}
+
+// VerifySignedURL checks a signature produced by Disk.SignedURL
+func (d *Disk) VerifySignedURL(key string, exp int64, sig string) bool {
+	if time.Now().Unix() > exp {
+		return false
+	}
+	expected := signKey(d.Secret, key, exp)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
+
+func signKey(secret, key string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(key + ":" + strconv.FormatInt(exp, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package pine
+
+import "net/http"
+
+// how many rows are written between flushes when streaming NDJSON
+const ndjsonFlushEvery = 100
+
+// NDJSON streams newline-delimited JSON to the client, meant for export
+// endpoints that produce millions of rows. Rows are written straight to the
+// wire and flushed periodically instead of being buffered in memory
+//
+// The iterator is called once and emits rows through yield:
+//
+//	return c.NDJSON(func(yield func(v interface{}) error) error {
+//		for rows.Next() {
+//			if err := yield(row); err != nil {
+//				return err
+//			}
+//		}
+//		return rows.Err()
+//	})
+//
+// yield returns an error when the row cannot be encoded or the client has
+// gone away, stop producing rows when that happens
+func (c *Ctx) NDJSON(iter func(yield func(v interface{}) error) error) error {
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Response.flushStatus()
+
+	flusher, _ := c.Response.ResponseWriter.(http.Flusher)
+	count := 0
+
+	yield := func(v interface{}) error {
+		// stop producing rows once the client has gone away
+		if c.IsAborted() {
+			return ErrClientAborted
+		}
+		raw, err := c.Server.config.JSONEncoder(v)
+		if err != nil {
+			return err
+		}
+		// write to the underlying writer directly, buffering an export of
+		// this size in the response wrapper would exhaust memory
+		if _, err := c.Response.ResponseWriter.Write(append(raw, '\n')); err != nil {
+			return err
+		}
+		count++
+		if flusher != nil && count%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	err := iter(yield)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return err
+}
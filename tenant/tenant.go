@@ -0,0 +1,223 @@
+// Pine's implementation of multi tenancy
+// This is a middleware that resolves the tenant making a request from the
+// subdomain, a header or the first path segment and stores it in the locals
+// of the request so that your handlers can read it with c.Locals
+
+// Per tenant rate limits and CORS origins can be plugged in through the
+// resolver callbacks in the Config. Lookups rely on Pine's in memory cache
+// in the same way the limiter package does
+
+package tenant
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/cache"
+)
+
+type Config struct {
+	// Defines the header the tenant is read from when it cannot be resolved
+	// from the subdomain
+	//
+	// Default: "X-Tenant-ID"
+	Header string
+
+	// By default the middleware first tries to resolve the tenant from the
+	// leftmost label of the request host
+	// Eg: a request to acme.example.com resolves the tenant "acme"
+	// Set this to true to skip the subdomain and start at the header
+	//
+	// Default: false
+	DisableSubdomain bool
+
+	// When set to true the first path segment is used as a fallback when
+	// neither the subdomain nor the header resolve a tenant
+	// Eg: a request to /acme/dashboard resolves the tenant "acme"
+	//
+	// Default: false
+	UsePathPrefix bool
+
+	// Resolver can be used to fully replace the built in resolution.
+	// Return an empty string if the tenant cannot be resolved
+	//
+	// Default: nil
+	Resolver func(c *pine.Ctx) string
+
+	// Defines the locals key the resolved tenant is stored under
+	//
+	// Default: "tenant"
+	LocalsKey string
+
+	// When set to true requests without a resolvable tenant are rejected
+	// using Handler
+	//
+	// Default: false
+	Required bool
+
+	// Defines the handler that will be called when a tenant is required but
+	// could not be resolved
+	//
+	// Default: returns a 400 status code
+	Handler pine.Handler
+
+	// AllowedOrigins resolves the CORS origins allowed for a tenant.
+	// When set and the request Origin is in the returned list, the
+	// Access-Control-Allow-Origin header is set to that origin
+	//
+	// Default: nil
+	AllowedOrigins func(tenant string) []string
+
+	// Limits resolves the rate limit for a tenant as the maximum number of
+	// requests allowed within the time window. Return a maxRequests of 0 to
+	// leave the tenant unlimited
+	//
+	// Default: nil
+	Limits func(tenant string) (maxRequests int, window time.Duration)
+
+	// Defines the store that will be used to store the rate limit data.
+	// This is an internal field and you should not need to change it or define it.
+	store *cache.Cache
+}
+
+// This is the structure of the rate limit data stored in the cache
+// This is for internal use and you should not need to change it
+type entry struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+func defaultHandler(c *pine.Ctx) error {
+	return c.SendStatus(http.StatusBadRequest)
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		Header:    "X-Tenant-ID",
+		LocalsKey: "tenant",
+		Handler:   defaultHandler,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Header != "" {
+			cfg.Header = userConfig.Header
+		}
+		if userConfig.DisableSubdomain {
+			cfg.DisableSubdomain = userConfig.DisableSubdomain
+		}
+		if userConfig.UsePathPrefix {
+			cfg.UsePathPrefix = userConfig.UsePathPrefix
+		}
+		if userConfig.Resolver != nil {
+			cfg.Resolver = userConfig.Resolver
+		}
+		if userConfig.LocalsKey != "" {
+			cfg.LocalsKey = userConfig.LocalsKey
+		}
+		if userConfig.Required {
+			cfg.Required = userConfig.Required
+		}
+		if userConfig.Handler != nil {
+			cfg.Handler = userConfig.Handler
+		}
+		if userConfig.AllowedOrigins != nil {
+			cfg.AllowedOrigins = userConfig.AllowedOrigins
+		}
+		if userConfig.Limits != nil {
+			cfg.Limits = userConfig.Limits
+		}
+	}
+	cfg.store = cache.New()
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			tenant := cfg.resolve(c)
+
+			if tenant == "" && cfg.Required {
+				return cfg.Handler(c)
+			}
+
+			// store the tenant so that handlers further down the stack can
+			// read it with c.Locals
+			c.Locals(cfg.LocalsKey, tenant)
+
+			if tenant != "" && cfg.AllowedOrigins != nil {
+				origin := c.Header("Origin")
+				for _, allowed := range cfg.AllowedOrigins(tenant) {
+					if allowed == "*" || strings.EqualFold(allowed, origin) {
+						c.Set("Access-Control-Allow-Origin", origin)
+						break
+					}
+				}
+			}
+
+			if tenant != "" && cfg.Limits != nil {
+				if !cfg.allow(tenant) {
+					return c.SendStatus(http.StatusTooManyRequests)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// resolve walks through the configured strategies in order:
+// custom resolver, subdomain, header and finally the path prefix
+func (cfg *Config) resolve(c *pine.Ctx) string {
+	if cfg.Resolver != nil {
+		return cfg.Resolver(c)
+	}
+
+	if !cfg.DisableSubdomain {
+		host := c.Request.Host
+		// strip the port if there is one
+		if i := strings.LastIndex(host, ":"); i != -1 {
+			host = host[:i]
+		}
+		// a subdomain needs at least three labels, Eg: acme.example.com
+		if labels := strings.Split(host, "."); len(labels) > 2 {
+			return labels[0]
+		}
+	}
+
+	if tenant := c.Header(cfg.Header); tenant != "" {
+		return tenant
+	}
+
+	if cfg.UsePathPrefix {
+		segments := strings.Split(strings.Trim(c.BaseURI, "/"), "/")
+		if len(segments) > 0 && segments[0] != "" {
+			return segments[0]
+		}
+	}
+	return ""
+}
+
+// allow checks the tenant against its resolved rate limit
+func (cfg *Config) allow(tenant string) bool {
+	maxRequests, window := cfg.Limits(tenant)
+	if maxRequests <= 0 {
+		return true
+	}
+
+	ent := cfg.store.Get(tenant)
+	if ent == nil {
+		cfg.store.Set(tenant, &entry{remaining: maxRequests - 1}, window)
+		return true
+	}
+
+	e := ent.(*entry)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.remaining == 0 {
+		return false
+	}
+	e.remaining--
+	return true
+}
@@ -0,0 +1,197 @@
+// TLS construction for the server
+// Start delegates here to turn the friendly TLSConfig struct into the
+// *tls.Config the net/http server needs. Mutual TLS is set up by pointing
+// ClientCAFile at the CA bundle client certificates are issued from,
+// handlers then read the verified peer through c.TLSPeerCertificates
+
+// The defaults are deliberately modern, TLS 1.2 is the floor and the
+// curve preferences favour X25519. OCSP stapling works off a sidecar
+// file the way haproxy does it, point OCSPFile at the DER response your
+// renewal tooling writes and Pine staples and refreshes it
+
+package pine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/BryanMwangi/pine/logger"
+)
+
+// hasCertificates reports whether the configuration names at least one
+// source of server certificates
+func (cfg TLSConfig) hasCertificates() bool {
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		return true
+	}
+	if len(cfg.Certificates) > 0 {
+		return true
+	}
+	return cfg.GetCertificate != nil
+}
+
+// buildTLSConfig translates the server's TLSConfig into a *tls.Config,
+// returning ErrTLSMisconfigured when the referenced files are unusable.
+// Called before binding so a broken configuration never holds a port
+func (server *Server) buildTLSConfig() (*tls.Config, error) {
+	cfg := server.config.TLSConfig
+
+	minVersion := cfg.MinVersion
+	if minVersion < tls.VersionTLS12 {
+		minVersion = tls.VersionTLS12
+	}
+
+	tlsConfig := &tls.Config{
+		ClientAuth:     cfg.ClientAuth,
+		GetCertificate: cfg.GetCertificate,
+		MinVersion:     minVersion,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+			tls.CurveP384,
+		},
+	}
+
+	// every extra pair is loaded up front, crypto/tls then picks the
+	// matching one per connection from the SNI in the client hello
+	for _, pair := range cfg.Certificates {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTLSMisconfigured, err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTLSMisconfigured, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates found in %s", ErrTLSMisconfigured, cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		// providing a CA bundle without a policy means the user wants
+		// mutual TLS, requiring and verifying is the only safe default
+		if cfg.ClientAuth == tls.NoClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	// stapling takes over certificate selection for the primary pair, it
+	// steps aside when the user already selects certificates themselves
+	if cfg.OCSPFile != "" && cfg.CertFile != "" &&
+		tlsConfig.GetCertificate == nil && len(tlsConfig.Certificates) == 0 {
+		stapler, err := newStapler(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTLSMisconfigured, err)
+		}
+		tlsConfig.GetCertificate = stapler.certificate
+		server.OnShutdown(stapler.stop)
+		go stapler.run()
+	}
+
+	return tlsConfig, nil
+}
+
+// serveTLS hands the listener to net/http. The certificate files are only
+// passed along when the tls.Config does not already select certificates,
+// net/http would otherwise load them into Certificates and shadow
+// GetCertificate
+func (server *Server) serveTLS(httpServer *http.Server, listener net.Listener) error {
+	if httpServer.TLSConfig != nil && httpServer.TLSConfig.GetCertificate != nil {
+		return httpServer.ServeTLS(listener, "", "")
+	}
+	cfg := server.config.TLSConfig
+	return httpServer.ServeTLS(listener, cfg.CertFile, cfg.KeyFile)
+}
+
+// ocspStapler keeps the primary certificate loaded with a fresh OCSP
+// staple attached, reloading both from disk on an interval so renewals
+// are picked up without a restart
+type ocspStapler struct {
+	cfg      TLSConfig
+	interval time.Duration
+	cert     atomic.Value
+	done     chan struct{}
+}
+
+func newStapler(cfg TLSConfig) (*ocspStapler, error) {
+	s := &ocspStapler{
+		cfg:      cfg,
+		interval: cfg.OCSPRefreshInterval,
+		done:     make(chan struct{}),
+	}
+	if s.interval == 0 {
+		s.interval = time.Hour
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the certificate pair and the staple file and swaps the
+// combined certificate in atomically
+func (s *ocspStapler) reload() error {
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+	staple, err := os.ReadFile(s.cfg.OCSPFile)
+	if err != nil {
+		return err
+	}
+	cert.OCSPStaple = staple
+	s.cert.Store(&cert)
+	return nil
+}
+
+// certificate serves the current stapled certificate, wired in as the
+// tls.Config's GetCertificate
+func (s *ocspStapler) certificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert.Load().(*tls.Certificate), nil
+}
+
+// run refreshes the staple until the server shuts down. A failed refresh
+// keeps the previous staple flowing, going stale beats going down
+func (s *ocspStapler) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.reload(); err != nil {
+				logger.RuntimeError("ocsp staple refresh failed: " + err.Error())
+			}
+		}
+	}
+}
+
+func (s *ocspStapler) stop() {
+	close(s.done)
+}
+
+// TLSPeerCertificates returns the verified certificate chain the client
+// presented, nil for plain HTTP connections and for clients that sent
+// none. The leaf certificate comes first, use it for authorization
+// decisions in handlers and middleware:
+//
+//	if certs := c.TLSPeerCertificates(); len(certs) > 0 {
+//		service := certs[0].Subject.CommonName
+//	}
+func (c *Ctx) TLSPeerCertificates() []*x509.Certificate {
+	if c.Request.TLS == nil {
+		return nil
+	}
+	return c.Request.TLS.PeerCertificates
+}
@@ -0,0 +1,158 @@
+// Pine's implementation of response body transformers
+// This is a middleware that buffers the response of the wrapped handlers and
+// applies a chain of transformers to it based on the response content type
+//
+// Two transformers ship with the package: an HTML minifier and a JSON field
+// filter driven by the ?fields= query. Apply the middleware per route or per
+// route group by wrapping only the handlers that should be transformed
+
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BryanMwangi/pine"
+)
+
+// Transformer rewrites a buffered response body.
+// ContentTypes filters which responses it applies to, matched by prefix so
+// that "text/html" also covers "text/html; charset=utf-8".
+// An empty list applies the transformer to every response
+type Transformer struct {
+	ContentTypes []string
+	Fn           func(c *pine.Ctx, body []byte) ([]byte, error)
+}
+
+// bufferWriter captures everything the handler writes so that the body can
+// be transformed before it reaches the client
+type bufferWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferWriter) Header() http.Header { return w.header }
+
+func (w *bufferWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+func (w *bufferWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func New(transformers ...Transformer) pine.Middleware {
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			// swap the underlying writer for a buffer while the handler runs
+			buf := &bufferWriter{header: make(http.Header)}
+			original := c.Response.ResponseWriter
+			c.Response.ResponseWriter = buf
+			err := next(c)
+			c.Response.ResponseWriter = original
+			if err != nil {
+				return err
+			}
+
+			body := buf.body.Bytes()
+			contentType := buf.header.Get("Content-Type")
+			for _, t := range transformers {
+				if !t.applies(contentType) {
+					continue
+				}
+				body, err = t.Fn(c, body)
+				if err != nil {
+					return err
+				}
+			}
+
+			// flush the buffered response to the real writer
+			for k, v := range buf.header {
+				original.Header()[k] = v
+			}
+			original.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			if buf.status != 0 {
+				original.WriteHeader(buf.status)
+			}
+			_, err = original.Write(body)
+			return err
+		}
+	}
+}
+
+func (t *Transformer) applies(contentType string) bool {
+	if len(t.ContentTypes) == 0 {
+		return true
+	}
+	for _, ct := range t.ContentTypes {
+		if strings.HasPrefix(contentType, ct) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinifyHTML returns a transformer that strips whitespace between HTML tags
+// and collapses indentation. It is intentionally conservative and leaves
+// anything inside <pre> and <textarea> alone by skipping documents that
+// contain those tags
+func MinifyHTML() Transformer {
+	return Transformer{
+		ContentTypes: []string{"text/html"},
+		Fn: func(c *pine.Ctx, body []byte) ([]byte, error) {
+			if bytes.Contains(body, []byte("<pre")) || bytes.Contains(body, []byte("<textarea")) {
+				return body, nil
+			}
+			lines := bytes.Split(body, []byte("\n"))
+			var out bytes.Buffer
+			for _, line := range lines {
+				trimmed := bytes.TrimSpace(line)
+				if len(trimmed) == 0 {
+					continue
+				}
+				// a line break is only safe to drop between two tags,
+				// anywhere else it is rendered whitespace and has to
+				// collapse to a single space instead
+				if out.Len() > 0 && !(out.Bytes()[out.Len()-1] == '>' && trimmed[0] == '<') {
+					out.WriteByte(' ')
+				}
+				out.Write(trimmed)
+			}
+			return out.Bytes(), nil
+		},
+	}
+}
+
+// JSONFields returns a transformer that filters the top level fields of a
+// JSON object response down to the ones listed in the ?fields= query,
+// Eg: GET /users/42?fields=id,name
+//
+// Responses that are not JSON objects and requests without the query are
+// passed through untouched
+func JSONFields() Transformer {
+	return Transformer{
+		ContentTypes: []string{"application/json"},
+		Fn: func(c *pine.Ctx, body []byte) ([]byte, error) {
+			fields := c.Query("fields")
+			if fields == "" {
+				return body, nil
+			}
+			var payload map[string]json.RawMessage
+			if err := json.Unmarshal(body, &payload); err != nil {
+				return body, nil
+			}
+			selected := make(map[string]json.RawMessage)
+			for _, field := range strings.Split(fields, ",") {
+				field = strings.TrimSpace(field)
+				if value, ok := payload[field]; ok {
+					selected[field] = value
+				}
+			}
+			return json.Marshal(selected)
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package pine
+
+// VersionInfo is the payload served by the version endpoint
+// AppName and Version come from the server config, the instance id is
+// generated once per server instance so that you can tell replicas apart
+type VersionInfo struct {
+	AppName    string `json:"app_name"`
+	Version    string `json:"version"`
+	InstanceID string `json:"instance_id"`
+}
+
+// VersionInfo returns the version metadata of this server instance
+func (server *Server) VersionInfo() VersionInfo {
+	return VersionInfo{
+		AppName:    server.config.AppName,
+		Version:    server.config.Version,
+		InstanceID: server.instanceID,
+	}
+}
+
+// VersionMiddleware stamps every response with the X-App-Version and
+// X-Instance-Id headers so that releases can be tracked from any response
+//
+// Register it like any other middleware:
+//
+//	app.Use(app.VersionMiddleware())
+func (server *Server) VersionMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(c *Ctx) error {
+			if server.config.Version != "" {
+				c.Set("X-App-Version", server.config.Version)
+			}
+			c.Set("X-Instance-Id", server.instanceID)
+			return next(c)
+		}
+	}
+}
+
+// ServeVersion registers a GET endpoint that returns the version metadata
+// of this server instance as JSON
+//
+// You can opt out of specifying the path and by default it will be
+// registered under /version
+func (server *Server) ServeVersion(path ...string) {
+	endpoint := "/version"
+	if len(path) > 0 && path[0] != "" {
+		endpoint = path[0]
+	}
+	server.Get(endpoint, func(c *Ctx) error {
+		return c.JSON(server.VersionInfo())
+	})
+}
@@ -0,0 +1,115 @@
+// Pine's WebDAV adapter
+// This lets you serve a directory as a file share on top of Pine's router.
+// This feature is experimental and may change in the future.
+//
+// The adapter is built on golang.org/x/net/webdav so the full RFC 4918
+// method set is supported, including PROPPATCH and LOCK/UNLOCK with an in
+// memory lock system, which clients such as macOS Finder and Windows
+// Explorer require before they will write to a share
+
+package webdav
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BryanMwangi/pine"
+	"golang.org/x/net/webdav"
+)
+
+type Config struct {
+	// Root is the directory on disk that will be served as the file share
+	//
+	// Default: "."
+	Root string
+
+	// Prefix is the route prefix the share is registered under.
+	// It is stripped from request paths before resolving files on disk
+	//
+	// Default: "/"
+	Prefix string
+
+	// FileSystem can be used to fully replace the directory backed store,
+	// Eg: webdav.NewMemFS() for an in memory share
+	//
+	// Default: webdav.Dir(Root)
+	FileSystem webdav.FileSystem
+
+	// LockSystem manages the locks clients take before writing
+	//
+	// Default: webdav.NewMemLS()
+	LockSystem webdav.LockSystem
+}
+
+// Call this to create a new WebDAV handler
+// Register the returned handler under a catch-all route for each of the
+// WebDAV methods or use Register to let Pine wire the routes for you
+//
+// Remember to add pine.WebDAVMethods to Config.RequestMethods when creating
+// the server, otherwise the routes will be rejected
+func New(config ...Config) pine.Handler {
+	cfg := Config{
+		Root:   ".",
+		Prefix: "/",
+	}
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.Root != "" {
+			cfg.Root = userConfig.Root
+		}
+		if userConfig.Prefix != "" {
+			cfg.Prefix = userConfig.Prefix
+		}
+		if userConfig.FileSystem != nil {
+			cfg.FileSystem = userConfig.FileSystem
+		}
+		if userConfig.LockSystem != nil {
+			cfg.LockSystem = userConfig.LockSystem
+		}
+	}
+	if cfg.FileSystem == nil {
+		cfg.FileSystem = webdav.Dir(cfg.Root)
+	}
+	if cfg.LockSystem == nil {
+		cfg.LockSystem = webdav.NewMemLS()
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     strings.TrimSuffix(cfg.Prefix, "/"),
+		FileSystem: cfg.FileSystem,
+		LockSystem: cfg.LockSystem,
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				pine.NotifyError(err, "webdav")
+			}
+		},
+	}
+
+	return func(c *pine.Ctx) error {
+		handler.ServeHTTP(c.Response, c.Request)
+		return nil
+	}
+}
+
+// Register wires the WebDAV handler on the server under the configured prefix
+// for every method the share needs, the WebDAV methods together with the
+// plain HTTP ones used to read and write files
+func Register(server *pine.Server, config ...Config) {
+	cfg := Config{Prefix: "/"}
+	if len(config) > 0 && config[0].Prefix != "" {
+		cfg.Prefix = config[0].Prefix
+	}
+	handler := New(config...)
+	route := strings.TrimSuffix(cfg.Prefix, "/") + "/*"
+	server.Propfind(route, handler)
+	server.Proppatch(route, handler)
+	server.Mkcol(route, handler)
+	server.Copy(route, handler)
+	server.Move(route, handler)
+	server.Lock(route, handler)
+	server.Unlock(route, handler)
+	server.Get(route, handler)
+	server.Put(route, handler)
+	server.Delete(route, handler)
+	server.Options(route, handler)
+}
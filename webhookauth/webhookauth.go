@@ -0,0 +1,178 @@
+// Pine's implementation of webhook signature verification
+// This is a middleware that verifies HMAC-SHA256 signatures over the raw
+// request body the way GitHub and Stripe sign their webhooks
+//
+// When a timestamp header is configured the timestamp is included in the
+// signed payload and checked against a tolerance window. Seen signatures are
+// remembered in Pine's in memory cache for the duration of that window to
+// protect against replay attacks
+
+package webhookauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/cache"
+)
+
+type Config struct {
+	// Secret is the shared secret used to compute the HMAC signature
+	//
+	// Required field
+	Secret string
+
+	// Defines the header the signature is read from
+	//
+	// Default: "X-Signature"
+	SignatureHeader string
+
+	// Defines an optional prefix stripped from the signature header value
+	// Eg: GitHub sends "sha256=<hex>"
+	//
+	// Default: "sha256="
+	SignaturePrefix string
+
+	// Defines the header the unix timestamp of the request is read from.
+	// When set the timestamp is prepended to the signed payload in the form
+	// "<timestamp>.<body>" the way Stripe signs webhooks
+	//
+	// Default: "X-Timestamp"
+	TimestampHeader string
+
+	// When set to true the timestamp is not checked and the raw body alone
+	// is signed. Only disable this when the provider does not send a
+	// timestamp, it is what protects against replayed requests
+	//
+	// Default: false
+	DisableTimestampCheck bool
+
+	// Defines how far a request timestamp may deviate from the server time
+	// before it is rejected. Also used as the replay protection window
+	//
+	// Default: 5 minutes
+	Tolerance time.Duration
+
+	// Defines the handler that will be called when verification fails
+	//
+	// Default: returns a 401 status code
+	Handler pine.Handler
+
+	// Defines the store that will be used to remember seen signatures.
+	// This is an internal field and you should not need to change it or define it.
+	store *cache.Cache
+}
+
+func defaultHandler(c *pine.Ctx) error {
+	return c.SendStatus(http.StatusUnauthorized)
+}
+
+func New(config ...Config) pine.Middleware {
+	cfg := Config{
+		SignatureHeader: "X-Signature",
+		SignaturePrefix: "sha256=",
+		TimestampHeader: "X-Timestamp",
+		Tolerance:       5 * time.Minute,
+		Handler:         defaultHandler,
+	}
+
+	// We check if the user has provided any configuration
+	// First config is always used as default
+	if len(config) > 0 {
+		userConfig := config[0]
+		cfg.Secret = userConfig.Secret
+		if userConfig.SignatureHeader != "" {
+			cfg.SignatureHeader = userConfig.SignatureHeader
+		}
+		if userConfig.SignaturePrefix != "" {
+			cfg.SignaturePrefix = userConfig.SignaturePrefix
+		}
+		if userConfig.TimestampHeader != "" {
+			cfg.TimestampHeader = userConfig.TimestampHeader
+		}
+		if userConfig.DisableTimestampCheck {
+			cfg.TimestampHeader = ""
+		}
+		if userConfig.Tolerance != 0 {
+			cfg.Tolerance = userConfig.Tolerance
+		}
+		if userConfig.Handler != nil {
+			cfg.Handler = userConfig.Handler
+		}
+	}
+	cfg.store = cache.New()
+
+	return func(next pine.Handler) pine.Handler {
+		return func(c *pine.Ctx) error {
+			if !cfg.verify(c) {
+				return cfg.Handler(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (cfg *Config) verify(c *pine.Ctx) bool {
+	signature := c.Header(cfg.SignatureHeader)
+	if signature == "" || cfg.Secret == "" {
+		return false
+	}
+	signature = strings.TrimPrefix(signature, cfg.SignaturePrefix)
+
+	// read the raw body and put it back so that handlers further down the
+	// stack can still bind it
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	payload := body
+	if cfg.TimestampHeader != "" {
+		timestamp := c.Header(cfg.TimestampHeader)
+		if !cfg.timestampValid(timestamp) {
+			return false
+		}
+		payload = append([]byte(timestamp+"."), body...)
+	}
+
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return false
+	}
+
+	// a valid signature may only be used once within the tolerance window
+	if cfg.store.Get(signature) != nil {
+		return false
+	}
+	cfg.store.Set(signature, struct{}{}, cfg.Tolerance)
+	return true
+}
+
+// timestampValid checks that the request timestamp is within the tolerance
+// window of the server time
+func (cfg *Config) timestampValid(timestamp string) bool {
+	if timestamp == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	diff := time.Since(time.Unix(ts, 0))
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= cfg.Tolerance
+}
@@ -0,0 +1,108 @@
+// Channels for managed websockets
+// A channel groups sessions so one Broadcast reaches every member. With
+// HistorySize set the channel retains its most recent messages and
+// replays them to clients as they join, so a chat window or a live
+// dashboard is never empty right after connecting
+
+package websocket
+
+import "sync"
+
+type ChannelConfig struct {
+	// The number of most recent broadcast messages the channel retains
+	// and replays to newly joined clients, zero keeps no history
+	//
+	// Default: 0
+	HistorySize int
+}
+
+// Channel is a named group of sessions that broadcast messages fan out to
+type Channel struct {
+	name   string
+	config ChannelConfig
+
+	mu      sync.Mutex
+	members map[*Session]struct{}
+	history [][]byte
+}
+
+// Channel returns the named channel, creating it on first use
+//
+// We check if the user has provided any configuration
+// The configuration only applies when the channel is created, later
+// lookups of the same name ignore it
+func (h *Hub) Channel(name string, config ...ChannelConfig) *Channel {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.channels == nil {
+		h.channels = make(map[string]*Channel)
+	}
+	if ch, ok := h.channels[name]; ok {
+		return ch
+	}
+	var cfg ChannelConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	ch := &Channel{
+		name:    name,
+		config:  cfg,
+		members: make(map[*Session]struct{}),
+	}
+	h.channels[name] = ch
+	return ch
+}
+
+// Name returns the name the channel was created under
+func (ch *Channel) Name() string {
+	return ch.name
+}
+
+// Join adds the session to the channel, replaying the retained history to
+// it first so the client starts with the recent messages it missed
+func (ch *Channel) Join(s *Session) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if _, ok := ch.members[s]; ok {
+		return
+	}
+	for _, data := range ch.history {
+		s.Send(data)
+	}
+	ch.members[s] = struct{}{}
+}
+
+// Leave removes the session from the channel. Sessions that expire are
+// cleaned out lazily by Broadcast, leaving explicitly is only needed when
+// a connected client switches channels
+func (ch *Channel) Leave(s *Session) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	delete(ch.members, s)
+}
+
+// Len returns the number of sessions currently in the channel
+func (ch *Channel) Len() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return len(ch.members)
+}
+
+// Broadcast sends data to every member and records it in the history.
+// Disconnected members buffer the message through their session, expired
+// ones are dropped from the channel
+func (ch *Channel) Broadcast(data []byte) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.config.HistorySize > 0 {
+		if len(ch.history) >= ch.config.HistorySize {
+			ch.history = ch.history[1:]
+		}
+		ch.history = append(ch.history, data)
+	}
+	for s := range ch.members {
+		if err := s.Send(data); err == ErrSessionExpired {
+			delete(ch.members, s)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Reconnect support for websocket connections
+// Mobile clients and flaky networks drop websocket connections all the
+// time. A Hub wraps connections in sessions identified by a resume token,
+// messages sent while the client is away are buffered for
+// ReconnectTimeout and flushed in order once the client reconnects with
+// its token, instead of being silently dropped
+
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/BryanMwangi/pine"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// HeaderResumeToken is the header the hub issues the session token on
+// during the handshake. Clients send the token back, in the same header
+// or the "resume" query parameter, to pick their session back up
+const HeaderResumeToken = "X-Resume-Token"
+
+// ErrSessionExpired is returned when sending on a session whose client
+// stayed away longer than ReconnectTimeout
+var ErrSessionExpired = errors.New("websocket: session expired")
+
+type SessionConfig struct {
+	// How long undelivered messages are kept for a disconnected client
+	// before the session and its buffer are dropped
+	//
+	// Default: 30 seconds
+	ReconnectTimeout time.Duration
+
+	// The maximum number of messages buffered per disconnected client,
+	// the oldest message is dropped when the buffer is full
+	//
+	// Default: 256
+	BufferSize int
+}
+
+var defaultSessionConfig = SessionConfig{
+	ReconnectTimeout: 30 * time.Second,
+	BufferSize:       256,
+}
+
+// Session is a connection that survives reconnects. Send through the
+// session rather than the raw connection and messages written while the
+// client is away are delivered when it comes back
+type Session struct {
+	token string
+	hub   *Hub
+
+	mu      sync.Mutex
+	conn    *Conn
+	pending [][]byte
+	expire  *time.Timer
+	closed  bool
+}
+
+// Hub tracks the sessions behind one handler
+type Hub struct {
+	config SessionConfig
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	channels map[string]*Channel
+}
+
+// NewHub creates a hub for resumable connections
+//
+// We check if the user has provided any configuration
+// If not, we use the default configuration
+func NewHub(config ...SessionConfig) *Hub {
+	cfg := defaultSessionConfig
+	if len(config) > 0 {
+		userConfig := config[0]
+		if userConfig.ReconnectTimeout != 0 {
+			cfg.ReconnectTimeout = userConfig.ReconnectTimeout
+		}
+		if userConfig.BufferSize != 0 {
+			cfg.BufferSize = userConfig.BufferSize
+		}
+	}
+	return &Hub{
+		config:   cfg,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Handler upgrades requests the same way New does but hands the handler a
+// resumable session. The session token is issued on the handshake
+// response in HeaderResumeToken, a client that reconnects with the token
+// receives everything sent while it was away
+func (h *Hub) Handler(handler func(s *Session, ctx *pine.Ctx), config ...Config) pine.Handler {
+	upgrader := newUpgrader(config...)
+
+	return func(ctx *pine.Ctx) error {
+		token := ctx.Query("resume")
+		if token == "" {
+			token = ctx.Header(HeaderResumeToken)
+		}
+		s := h.session(token)
+
+		// issued before the upgrade so the token rides on the 101 response
+		ctx.Set(HeaderResumeToken, s.token)
+
+		Conn, err := upgrader.Upgrade(ctx.Response.ResponseWriter, ctx.Request, ctx.Response.Header())
+		if err != nil {
+			pine.NotifyError(err, "websocket")
+			return err
+		}
+
+		conn := acquireConn()
+		conn.Conn = Conn
+		connections.Inc()
+		defer func() {
+			connections.Add(-1)
+			s.detach(conn)
+			releaseConn(conn)
+		}()
+		s.attach(conn)
+		handler(s, ctx)
+		return nil
+	}
+}
+
+// session returns the existing session for token or a fresh one when the
+// token is empty, unknown or already expired
+func (h *Hub) session(token string) *Session {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if token != "" {
+		if s, ok := h.sessions[token]; ok {
+			return s
+		}
+	}
+	s := &Session{
+		token: uuid.NewString(),
+		hub:   h,
+	}
+	h.sessions[s.token] = s
+	return s
+}
+
+// drop removes an expired session from the hub
+func (h *Hub) drop(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, token)
+}
+
+// Token returns the resume token the client must present to pick this
+// session back up after a disconnect
+func (s *Session) Token() string {
+	return s.token
+}
+
+// Connected reports whether the client is currently attached
+func (s *Session) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+// Send writes data to the client as a text message. While the client is
+// disconnected the message is buffered and delivered in order on resume,
+// ErrSessionExpired is returned once the client has stayed away longer
+// than ReconnectTimeout
+func (s *Session) Send(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSessionExpired
+	}
+	if s.conn != nil {
+		if err := s.conn.WriteMessage(websocket.TextMessage, data); err == nil {
+			return nil
+		}
+		// the write failed so the connection is as good as gone, keep the
+		// message for the reconnect instead of losing it
+	}
+	s.buffer(data)
+	return nil
+}
+
+// buffer queues data for redelivery, must be called with the mutex held
+func (s *Session) buffer(data []byte) {
+	if len(s.pending) >= s.hub.config.BufferSize {
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, data)
+}
+
+// attach binds a new connection to the session and flushes everything
+// buffered while the client was away
+func (s *Session) attach(conn *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expire != nil {
+		s.expire.Stop()
+		s.expire = nil
+	}
+	s.conn = conn
+
+	pending := s.pending
+	s.pending = nil
+	for i, data := range pending {
+		if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			// connection died mid flush, keep what was not delivered
+			s.pending = append(pending[i:], s.pending...)
+			return
+		}
+	}
+}
+
+// detach marks the client as away and starts the expiry clock. Only the
+// connection that is currently attached detaches the session, a stale
+// handler returning after a resume must not tear down the new connection
+func (s *Session) detach(conn *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != conn {
+		return
+	}
+	s.conn = nil
+	s.expire = time.AfterFunc(s.hub.config.ReconnectTimeout, func() {
+		s.mu.Lock()
+		if s.conn != nil {
+			// the client made it back just in time
+			s.mu.Unlock()
+			return
+		}
+		s.closed = true
+		s.pending = nil
+		s.mu.Unlock()
+		s.hub.drop(s.token)
+	})
+}
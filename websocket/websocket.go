@@ -5,15 +5,19 @@
 package websocket
 
 import (
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/BryanMwangi/pine"
+	"github.com/BryanMwangi/pine/metrics"
 	"github.com/gorilla/websocket"
 )
 
+// connections tracks currently open websocket connections, exported
+// through the metrics package
+var connections = metrics.NewCounter("websocket_connections")
+
 // Config is a struct that holds the configuration for the websocket server
 type Config struct {
 	// ReadBufferSize and WriteBufferSize specify I/O buffer sizes in bytes. If a buffer
@@ -87,10 +91,9 @@ func releaseConn(conn *Conn) {
 	poolConn.Put(conn)
 }
 
-// Called to open a new connection and upgrade it to a websocket connection
-// this is the main function to use to create a new websocket connection
-// Use this function if the Type is set to "self"
-func New(handler func(conn *Conn, ctx *pine.Ctx), config ...Config) pine.Handler {
+// newUpgrader builds the upgrader from the user configuration, shared by
+// New and the session aware handlers
+func newUpgrader(config ...Config) websocket.Upgrader {
 	var cfg Config
 	if len(config) > 0 {
 		userConfig := config[0]
@@ -119,7 +122,7 @@ func New(handler func(conn *Conn, ctx *pine.Ctx), config ...Config) pine.Handler
 		cfg = defaultConfig
 	}
 
-	var upgrader = websocket.Upgrader{
+	return websocket.Upgrader{
 		ReadBufferSize:    cfg.ReadBufferSize,
 		WriteBufferSize:   cfg.WriteBufferSize,
 		CheckOrigin:       cfg.CheckOrigin,
@@ -128,17 +131,28 @@ func New(handler func(conn *Conn, ctx *pine.Ctx), config ...Config) pine.Handler
 		EnableCompression: cfg.EnableCompression,
 		HandshakeTimeout:  cfg.HandshakeTimeout,
 	}
+}
+
+// Called to open a new connection and upgrade it to a websocket connection
+// this is the main function to use to create a new websocket connection
+// Use this function if the Type is set to "self"
+func New(handler func(conn *Conn, ctx *pine.Ctx), config ...Config) pine.Handler {
+	upgrader := newUpgrader(config...)
 
 	return func(ctx *pine.Ctx) error {
 		Conn, err := upgrader.Upgrade(ctx.Response.ResponseWriter, ctx.Request, ctx.Response.Header())
 		if err != nil {
-			fmt.Println(err)
+			pine.NotifyError(err, "websocket")
 			return err
 		}
 
 		conn := acquireConn()
 		conn.Conn = Conn
-		defer releaseConn(conn)
+		connections.Inc()
+		defer func() {
+			connections.Add(-1)
+			releaseConn(conn)
+		}()
 		handler(conn, ctx)
 		return nil
 	}
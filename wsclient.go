@@ -0,0 +1,174 @@
+package pine
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// how reconnect attempts back off, the delay doubles from the initial value
+// up to the maximum and resets after a successful dial
+const (
+	wsInitialBackoff = 500 * time.Millisecond
+	wsMaxBackoff     = 30 * time.Second
+)
+
+// ErrWebsocketClosed is returned from reads and writes after Close
+var ErrWebsocketClosed = errors.New("websocket connection is closed")
+
+// WebsocketConn is a managed outbound websocket connection created with
+// Client.Websocket. Reads and writes transparently reconnect with
+// exponential backoff when the connection drops, so consuming an upstream
+// feed does not need its own retry plumbing
+type WebsocketConn struct {
+	url         string
+	headers     http.Header
+	jsonEncoder JSONMarshal
+	jsonDecoder JSONUnmarshal
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+}
+
+// Websocket dials an outbound websocket connection,
+// Eg: client.Websocket("wss://feed.example.com/ticks", nil)
+//
+// headers are optional and sent with the handshake, useful for bearer
+// tokens. The returned connection reconnects on its own, call Close when
+// the feed is no longer needed
+func (c *Client) Websocket(url string, headers map[string]string) (*WebsocketConn, error) {
+	h := make(http.Header)
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+
+	ws := &WebsocketConn{
+		url:         url,
+		headers:     h,
+		jsonEncoder: json.Marshal,
+		jsonDecoder: json.Unmarshal,
+	}
+	if err := ws.dial(); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// SendJSON encodes v with the client's JSON codec and writes it as a text
+// message, reconnecting and retrying once when the connection has dropped
+func (ws *WebsocketConn) SendJSON(v interface{}) error {
+	raw, err := ws.jsonEncoder(v)
+	if err != nil {
+		return err
+	}
+	return ws.Send(raw)
+}
+
+// Send writes a text message, reconnecting and retrying once when the
+// connection has dropped
+func (ws *WebsocketConn) Send(message []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return ErrWebsocketClosed
+	}
+
+	if err := ws.conn.WriteMessage(websocket.TextMessage, message); err == nil {
+		return nil
+	}
+	if err := ws.redialLocked(); err != nil {
+		return err
+	}
+	return ws.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// Read blocks until the next message arrives, reconnecting behind the
+// scenes when the connection drops. It only returns an error once the
+// connection has been closed with Close
+func (ws *WebsocketConn) Read() ([]byte, error) {
+	for {
+		ws.mu.Lock()
+		if ws.closed {
+			ws.mu.Unlock()
+			return nil, ErrWebsocketClosed
+		}
+		conn := ws.conn
+		ws.mu.Unlock()
+
+		_, message, err := conn.ReadMessage()
+		if err == nil {
+			return message, nil
+		}
+
+		ws.mu.Lock()
+		err = ws.redialLocked()
+		ws.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it into dest with the
+// client's JSON codec
+func (ws *WebsocketConn) ReadJSON(dest interface{}) error {
+	message, err := ws.Read()
+	if err != nil {
+		return err
+	}
+	return ws.jsonDecoder(message, dest)
+}
+
+// Close tears the connection down, pending reads return ErrWebsocketClosed
+func (ws *WebsocketConn) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	if ws.conn != nil {
+		return ws.conn.Close()
+	}
+	return nil
+}
+
+// dial establishes the underlying connection
+func (ws *WebsocketConn) dial() error {
+	conn, _, err := websocket.DefaultDialer.Dial(ws.url, ws.headers)
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	ws.conn = conn
+	ws.mu.Unlock()
+	return nil
+}
+
+// redialLocked reconnects with exponential backoff until it succeeds or the
+// connection is closed, the caller must hold the mutex
+func (ws *WebsocketConn) redialLocked() error {
+	backoff := wsInitialBackoff
+	for {
+		if ws.closed {
+			return ErrWebsocketClosed
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(ws.url, ws.headers)
+		if err == nil {
+			ws.conn = conn
+			return nil
+		}
+		// release the lock while waiting so Close can still get through
+		ws.mu.Unlock()
+		time.Sleep(backoff)
+		ws.mu.Lock()
+		if backoff < wsMaxBackoff {
+			backoff *= 2
+		}
+	}
+}